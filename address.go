@@ -0,0 +1,65 @@
+package ihex
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// addressExtensionDataSize is the data length of RecordExtSegment and RecordExtLinear records
+	addressExtensionDataSize = 2
+
+	// addressStartDataSize is the data length of RecordStartSegment and RecordStartLinear records
+	addressStartDataSize = 4
+)
+
+// addressState tracks the extended address in effect while walking the records of a HEX file in order.
+type addressState struct {
+	base uint32
+}
+
+// next applies a record to this address state.
+// Extended address records update the base address that is applied to all of the data records that follow them.
+// Returns the absolute address of the record's first data byte or an error if an address record's data is the wrong length.
+func (me *addressState) next(r Record) (uint32, error) {
+
+	switch r.Type {
+	case RecordExtSegment, RecordExtLinear:
+		if len(r.Data) != addressExtensionDataSize {
+			return 0, &InvalidRecordError{
+				Message: fmt.Sprintf("Extended address record must contain %d data bytes. Data bytes detected: %d", addressExtensionDataSize, len(r.Data)),
+				Kind:    ErrStructure,
+				Column:  recordDataIndex*2 + 2,
+			}
+		}
+
+		if r.Type == RecordExtSegment {
+			me.base = uint32(binary.BigEndian.Uint16(r.Data)) << 4
+		} else {
+			me.base = uint32(binary.BigEndian.Uint16(r.Data)) << 16
+		}
+	case RecordStartSegment, RecordStartLinear:
+		if len(r.Data) != addressStartDataSize {
+			return 0, &InvalidRecordError{
+				Message: fmt.Sprintf("Start address record must contain %d data bytes. Data bytes detected: %d", addressStartDataSize, len(r.Data)),
+				Kind:    ErrStructure,
+				Column:  recordDataIndex*2 + 2,
+			}
+		}
+	}
+
+	return me.base + uint32(r.AddressOffset), nil
+}
+
+// addressUnitSize returns the number of bytes addressed by each step of a record's address for the specified address unit.
+// An address unit of 0 addresses single bytes. Returns an error if the address unit is not 1, 2 or 4 bytes.
+func addressUnitSize(unit int) (int, error) {
+
+	switch unit {
+	case 0:
+		return 1, nil
+	case 1, 2, 4:
+		return unit, nil
+	}
+	return 0, fmt.Errorf("Unsupported address unit: %d bytes. Supported address units are 1, 2 and 4 bytes", unit)
+}
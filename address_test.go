@@ -0,0 +1,184 @@
+package ihex
+
+import (
+	"bytes"
+	"testing"
+)
+
+// checkRecords fails the test if the records of a file are not the expected records
+func checkRecords(t *testing.T, f File, want []Record) {
+
+	t.Helper()
+
+	if f.Len() != len(want) {
+		t.Fatalf("file has %d records, expected %d", f.Len(), len(want))
+	}
+
+	for i, r := range f.All() {
+		if r.Type != want[i].Type || r.AddressOffset != want[i].AddressOffset || !bytes.Equal(r.Data, want[i].Data) {
+			t.Fatalf("record %d is %+v, expected %+v", i, r, want[i])
+		}
+	}
+}
+
+func TestFileWriterAddressUnitSegments(t *testing.T) {
+
+	for _, c := range []struct {
+		name     string
+		fileType FileType
+		unit     int
+		address  uint32
+		want     []Record
+	}{
+		{"Unit2", I32HEX, 2, 0x1FFF8, []Record{
+			{Type: RecordData, AddressOffset: 0xFFFC, Data: testData(16)[:8]},
+			{Type: RecordExtLinear, Data: []byte{0x00, 0x01}},
+			{Type: RecordData, AddressOffset: 0x0000, Data: testData(16)[8:]},
+			{Type: RecordEOF, Data: []byte{}},
+		}},
+		{"Unit4", I32HEX, 4, 0x3FFF8, []Record{
+			{Type: RecordData, AddressOffset: 0xFFFE, Data: testData(16)[:8]},
+			{Type: RecordExtLinear, Data: []byte{0x00, 0x01}},
+			{Type: RecordData, AddressOffset: 0x0000, Data: testData(16)[8:]},
+			{Type: RecordEOF, Data: []byte{}},
+		}},
+		{"Unit2I16HEX", I16HEX, 2, 0x1FFF8, []Record{
+			{Type: RecordData, AddressOffset: 0xFFFC, Data: testData(16)[:8]},
+			{Type: RecordExtSegment, Data: []byte{0x10, 0x00}},
+			{Type: RecordData, AddressOffset: 0x0000, Data: testData(16)[8:]},
+			{Type: RecordEOF, Data: []byte{}},
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			fw, err := NewFileWriterOptions(&buf, 16, c.fileType, WriteOptions{AddressUnit: c.unit})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fw.SetAddress(c.address); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := fw.Write(testData(16)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := NewFile(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRecords(t, f, c.want)
+
+			img, err := NewImageUnit(f, c.unit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkImage(t, img, &Image{Segments: []Segment{{Address: c.address, Data: testData(16)}}})
+		})
+	}
+}
+
+func TestFileWriterAddressUnitAlignment(t *testing.T) {
+
+	for _, c := range []struct {
+		unit    int
+		address uint32
+	}{
+		{2, 0x0001},
+		{4, 0x0002},
+		{4, 0x10003},
+	} {
+		fw, err := NewFileWriterOptions(&bytes.Buffer{}, 16, I32HEX, WriteOptions{AddressUnit: c.unit})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fw.SetAddress(c.address); err == nil {
+			t.Fatalf("setting address %08X with an address unit of %d bytes did not fail", c.address, c.unit)
+		}
+	}
+
+	// a partial word may only be written at the very end, since the data after it would not start on a word boundary
+	fw, err := NewFileWriterOptions(&bytes.Buffer{}, 4, I32HEX, WriteOptions{AddressUnit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write([]byte{4, 5, 6, 7}); err == nil {
+		t.Fatal("writing after a partial word did not fail")
+	}
+
+	if _, err := NewFileWriterOptions(&bytes.Buffer{}, 15, I32HEX, WriteOptions{AddressUnit: 2}); err == nil {
+		t.Fatal("a record size that is not a multiple of the address unit did not fail")
+	}
+
+	if _, err := NewFileWriterOptions(&bytes.Buffer{}, 15, I32HEX, WriteOptions{AddressUnit: 3}); err == nil {
+		t.Fatal("an address unit of 3 bytes did not fail")
+	}
+}
+
+func TestWordAddressedFileRoundTrip(t *testing.T) {
+
+	img := &Image{Segments: make([]Segment, 0)}
+	img.Set(0x0000, testData(20))
+	img.Set(0x1FFF0, testData(32))
+	img.Start = 0x1234
+	img.HasStart = true
+
+	bytesFile, err := img.File(I32HEX, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, unit := range []int{1, 2, 4} {
+		words, err := WordAddressedFile(bytesFile, unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the second record of the file starts 16 bytes into the image, which is word 16 / unit
+		if r := words.Records()[1]; r.Type != RecordData || r.AddressOffset != uint16(16/unit) {
+			t.Fatalf("unit %d: second record is %+v, expected data at word %X", unit, r, 16/unit)
+		}
+
+		back, err := ByteAddressedFile(words, unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		backImg, err := NewImage(back)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkImage(t, backImg, img)
+	}
+
+	// data that does not start on a word boundary cannot be written with word addresses
+	odd := &Image{Segments: []Segment{{Address: 0x0001, Data: []byte{1, 2}}}}
+	oddFile, err := odd.File(I32HEX, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := WordAddressedFile(oddFile, 2); err == nil {
+		t.Fatal("converting data at an odd address to a word-addressed file did not fail")
+	}
+
+	if _, err := ByteAddressedFile(bytesFile, 3); err == nil {
+		t.Fatal("converting with an address unit of 3 bytes did not fail")
+	}
+}
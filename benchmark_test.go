@@ -0,0 +1,146 @@
+package ihex
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// benchmarkDataSize is the number of data bytes in the HEX file used by the benchmarks
+const benchmarkDataSize = 4 << 20
+
+// benchmarkHex returns an I32HEX file holding size bytes of data in records of 32 bytes
+func benchmarkHex(tb testing.TB, size int) []byte {
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 32)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if _, err := fw.Write(data); err != nil {
+		tb.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkParse measures decoding records one at a time with a RecordReader
+func BenchmarkParse(b *testing.B) {
+
+	src := benchmarkHex(b, benchmarkDataSize)
+
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rr := NewRecordReader(bytes.NewReader(src))
+
+		for {
+			if _, err := rr.Read(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkWrite measures encoding records one at a time with a RecordWriter
+func BenchmarkWrite(b *testing.B) {
+
+	f, err := NewFile(bytes.NewReader(benchmarkHex(b, benchmarkDataSize)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	records := f.Records()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rw := NewRecordWriter(io.Discard)
+		sum := int64(0)
+
+		for _, r := range records {
+			n, err := rw.WriteRecord(r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			sum += int64(n)
+		}
+
+		if err := rw.Flush(); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(sum)
+	}
+}
+
+// BenchmarkFileWriter measures converting binary data to HEX records with a FileWriter
+func BenchmarkFileWriter(b *testing.B) {
+
+	data := make([]byte, benchmarkDataSize)
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		fw, err := NewFileWriter(io.Discard, 32)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// small writes, as an io.Copy from a slow source would make
+		for off := 0; off < len(data); off += 512 {
+			if _, err := fw.Write(data[off : off+512]); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		if err := fw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNewFileConcurrency compares reading a large file with the single goroutine scanner loop against the concurrent chunked parser
+func BenchmarkNewFileConcurrency(b *testing.B) {
+
+	src := benchmarkHex(b, 4*benchmarkDataSize)
+
+	for _, c := range []struct {
+		name        string
+		concurrency int
+	}{
+		{"Concurrency1", 1},
+		{"Concurrency2", 2},
+		{"Concurrency4", 4},
+		{"ConcurrencyNumCPU", runtime.NumCPU()},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			opts := ParseOptions{Concurrency: c.concurrency}
+
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := NewFileWithOptions(context.Background(), bytes.NewReader(src), opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package ihex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// coeRadixKey is the COE keyword that sets the radix of the memory initialization vector
+	coeRadixKey = "memory_initialization_radix"
+
+	// coeVectorKey is the COE keyword that holds the values of the memory, starting at word address 0
+	coeVectorKey = "memory_initialization_vector"
+)
+
+// ReadCOE reads a Xilinx COE file and creates an image of its contents.
+// The memory_initialization_vector holds the value of every word of the memory, starting at word address 0, in the radix set by memory_initialization_radix.
+// Words are split into bytes using opts.WordWidth and opts.BigEndian. Lines starting with ';' are comments, and other keywords are skipped.
+// Reading stops with a LimitExceededError once the vector holds more than opts.MaxBytes bytes of memory.
+// Returns the new image or an InvalidRecordError pointing at the first malformed keyword or value.
+func ReadCOE(r io.Reader, opts MemoryOptions) (*Image, error) {
+
+	wb, err := memoryWordBytes(opts.WordWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	t := newMemoryTokenizer(src)
+	word := make([]byte, wb)
+	radix := 0
+
+	for {
+		key, ok := t.next()
+		if !ok {
+			return img, nil
+		}
+
+		// a ';' that does not end a statement starts a comment
+		if key.is(";") {
+			t.skipLine()
+			continue
+		}
+
+		if _, err := t.expect("="); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case key.is(coeRadixKey):
+			tok, _ := t.next()
+			radix, _ = strconv.Atoi(string(tok.text))
+
+			if radix != 2 && radix != 10 && radix != 16 {
+				return nil, t.errorf(tok, ErrStructure, "COE radix must be 2, 10 or 16. Found: '%s'", tok.text)
+			}
+
+			if _, err := t.expect(";"); err != nil {
+				return nil, err
+			}
+
+		case key.is(coeVectorKey):
+			if radix == 0 {
+				return nil, t.errorf(key, ErrStructure, "%s must follow %s", coeVectorKey, coeRadixKey)
+			}
+
+			address := uint64(0)
+			for {
+				tok, ok := t.next()
+				if !ok {
+					return nil, t.errorf(tok, ErrStructure, "%s must end with ';'", coeVectorKey)
+				}
+
+				if tok.is(";") {
+					break
+				}
+
+				if tok.is(",") {
+					continue
+				}
+
+				value, err := parseMemoryWord(tok.text, radix, wb)
+				if err != nil {
+					return nil, t.errorf(tok, ErrBadHexDigit, "%s", err.Error())
+				}
+
+				if (address+1)*uint64(wb) > 1<<32 {
+					return nil, t.errorf(tok, ErrStructure, "Word address %X is past the end of the 32 bit address space", address)
+				}
+
+				if err := opts.checkBytes((address + 1) * uint64(wb)); err != nil {
+					return nil, err
+				}
+
+				unpackWord(word, value, opts.BigEndian)
+				img.Set(uint32(address*uint64(wb)), word)
+				address++
+			}
+
+		default:
+			for {
+				tok, ok := t.next()
+				if !ok {
+					return nil, t.errorf(tok, ErrStructure, "%s must end with ';'", key.text)
+				}
+
+				if tok.is(";") {
+					break
+				}
+			}
+		}
+	}
+}
+
+// WriteCOE writes the data of an image to the provided writer as a Xilinx COE file.
+// Every word of the memory, from word address 0 to opts.Depth, is written to the memory_initialization_vector in the radix set by opts.Radix.
+// Words that have no data in the image are written as opts.Fill.
+// Returns an error if the options are invalid or the image does not fit in opts.Depth words, a LimitExceededError if the memory is larger than opts.MaxBytes,
+// or any errors generated by the provided writer.
+func WriteCOE(w io.Writer, img *Image, opts MemoryOptions) error {
+
+	radix := opts.radix()
+	if radix != 2 && radix != 10 && radix != 16 {
+		return fmt.Errorf("Unsupported COE radix: %d. Supported radixes are 2, 10 and 16", radix)
+	}
+
+	data, wb, err := opts.words(img)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "%s=%d;\n%s=\n", coeRadixKey, radix, coeVectorKey); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, 66)
+	for i := 0; i < len(data); i += wb {
+		buf = appendMemoryWord(buf[:0], packWord(data[i:i+wb], opts.BigEndian), radix, wb)
+
+		if i+wb < len(data) {
+			buf = append(buf, ",\n"...)
+		} else {
+			buf = append(buf, ";\n"...)
+		}
+
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,66 @@
+package ihex
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+)
+
+var (
+	// compressGzipMagic is the magic number at the start of gzip compressed data
+	compressGzipMagic = []byte{0x1F, 0x8B}
+
+	// compressBzip2Magic is the magic number at the start of bzip2 compressed data
+	compressBzip2Magic = []byte("BZh")
+)
+
+// decompressReader is a reader that transparently decompresses gzip and bzip2 compressed input.
+// The compression is detected from the magic number at the start of the input on the first call to Read. Uncompressed input is read unchanged.
+type decompressReader struct {
+	reader   io.Reader
+	detected bool
+	err      error
+}
+
+// Read reads decompressed data into p.
+// Returns the number of bytes read and any errors from the underlying reader or the decompressor.
+func (me *decompressReader) Read(p []byte) (int, error) {
+
+	if !me.detected {
+		me.detected = true
+		me.reader, me.err = decompress(me.reader)
+	}
+
+	if me.err != nil {
+		return 0, me.err
+	}
+	return me.reader.Read(p)
+}
+
+// newDecompressReader creates a reader that decompresses r if it is gzip or bzip2 compressed
+func newDecompressReader(r io.Reader) *decompressReader {
+	return &decompressReader{
+		reader: r,
+	}
+}
+
+// decompress checks the start of r for the magic number of a supported compression format.
+// Returns a reader of the decompressed data of r, a reader of r unchanged if it is not compressed, or any errors reading r or the compressed data's header.
+func decompress(r io.Reader) (io.Reader, error) {
+
+	br := bufio.NewReader(r)
+
+	head, err := br.Peek(len(compressBzip2Magic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(head, compressGzipMagic) {
+		return gzip.NewReader(br)
+	} else if bytes.HasPrefix(head, compressBzip2Magic) {
+		return bzip2.NewReader(br), nil
+	}
+	return br, nil
+}
@@ -0,0 +1,191 @@
+package ihex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// closeRecorder is a writer that keeps a copy of everything written to it before Close was called
+type closeRecorder struct {
+	bytes.Buffer
+	closed []byte
+}
+
+// Close records the data written so far
+func (me *closeRecorder) Close() error {
+
+	if me.closed != nil {
+		return errors.New("writer closed twice")
+	}
+	me.closed = append(make([]byte, 0, me.Len()), me.Bytes()...)
+	return nil
+}
+
+func TestCompressedInput(t *testing.T) {
+
+	plain, err := os.ReadFile("testdata/objcopy_i32.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := NewFile(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantImg, err := NewImage(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"objcopy_i32.hex.gz", "objcopy_i32.hex.bz2"} {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile("testdata/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := NewFile(bytes.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRecords(t, f, want.Records())
+
+			// the concurrent parser reads through the same decompressor
+			f, err = NewFileWithOptions(context.Background(), bytes.NewReader(src), ParseOptions{Concurrency: 2, ChunkSize: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRecords(t, f, want.Records())
+
+			rr := NewRecordReaderOptions(bytes.NewReader(src), ParseOptions{})
+			for i := 0; ; i++ {
+				r, err := rr.Read()
+				if err == io.EOF {
+					if i != want.Len() {
+						t.Fatalf("record reader read %d records, expected %d", i, want.Len())
+					}
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				if w := want.Records()[i]; r.Type != w.Type || r.AddressOffset != w.AddressOffset || !bytes.Equal(r.Data, w.Data) {
+					t.Fatalf("record reader read record %d as %+v, expected %+v", i, r, w)
+				}
+			}
+
+			img, format, err := Open(bytes.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if format.Name() != "intelhex" {
+				t.Fatalf("compressed input was detected as %s", format.Name())
+			}
+			checkImage(t, img, wantImg)
+		})
+	}
+}
+
+func TestCompressedInputErrors(t *testing.T) {
+
+	// a gzip magic number followed by a corrupt header is reported instead of being parsed as records
+	if _, err := NewFile(bytes.NewReader([]byte{0x1F, 0x8B, 0x00})); err == nil {
+		t.Fatal("reading a corrupt gzip header did not fail")
+	}
+
+	src, err := os.ReadFile("testdata/objcopy_i32.hex.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFile(bytes.NewReader(src[:len(src)/2])); err == nil {
+		t.Fatal("reading truncated bzip2 data did not fail")
+	}
+}
+
+func TestWriteFileGzip(t *testing.T) {
+
+	f, err := testImage().File(I32HEX, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var plain, compressed bytes.Buffer
+	if err := WriteFileWithOptions(context.Background(), f, &plain, WriteOptions{Lowercase: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileWithOptions(context.Background(), f, &compressed, WriteOptions{Lowercase: true, Gzip: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(compressed.Bytes(), compressGzipMagic) {
+		t.Fatal("output does not start with the gzip magic number")
+	}
+
+	gz, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the other options still apply to the compressed records
+	out, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, plain.Bytes()) {
+		t.Fatalf("decompressed output:\n%s\nexpected:\n%s", out, plain.Bytes())
+	}
+}
+
+func TestFileWriterGzip(t *testing.T) {
+
+	var plain bytes.Buffer
+	w := &closeRecorder{}
+
+	for _, dst := range []io.Writer{&plain, w} {
+		fw, err := NewFileWriterOptions(dst, 16, I32HEX, WriteOptions{Gzip: dst == w})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := fw.Write(testData(100)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the gzip stream must be complete before the underlying writer is closed
+	if w.closed == nil {
+		t.Fatal("underlying writer was not closed")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(w.closed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("gzip stream was not complete when the underlying writer was closed: %v", err)
+	}
+
+	if !bytes.Equal(out, plain.Bytes()) {
+		t.Fatalf("decompressed output:\n%s\nexpected:\n%s", out, plain.Bytes())
+	}
+
+	if w.Len() != len(w.closed) {
+		t.Fatalf("%d bytes were written after the underlying writer was closed", w.Len()-len(w.closed))
+	}
+}
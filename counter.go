@@ -0,0 +1,31 @@
+package ihex
+
+import "io"
+
+// countingReader wraps a reader and keeps track of how many bytes have been read from it.
+// If limit is greater than 0, reading more than limit bytes fails with a LimitExceededError.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+	limit  int64
+}
+
+// Read reads from the underlying reader and adds the number of bytes read to the running count
+func (me *countingReader) Read(p []byte) (int, error) {
+
+	// read at most one byte past the limit, which is enough to tell whether the input exceeds it
+	if me.limit > 0 && int64(len(p)) > me.limit-me.count+1 {
+		p = p[:me.limit-me.count+1]
+	}
+
+	n, err := me.reader.Read(p)
+	me.count += int64(n)
+
+	if me.limit > 0 && me.count > me.limit {
+		return n, &LimitExceededError{
+			Limit: "MaxInputBytes",
+			Max:   me.limit,
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,272 @@
+package ihex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// dfusePrefixSize is the size of the prefix at the start of a DfuSe file
+	dfusePrefixSize = 11
+
+	// dfuseTargetPrefixSize is the size of the prefix at the start of each target of a DfuSe file
+	dfuseTargetPrefixSize = 274
+
+	// dfuseTargetNameSize is the size of the target name field of a DfuSe target prefix
+	dfuseTargetNameSize = 255
+
+	// dfuseElementHeaderSize is the size of the address and size fields at the start of each element of a DfuSe target
+	dfuseElementHeaderSize = 8
+
+	// dfuSuffixSize is the size of the DFU suffix at the end of a DfuSe file
+	dfuSuffixSize = 16
+
+	// dfuseVersion is the version of the DfuSe file format
+	dfuseVersion = 0x01
+
+	// dfuseBcdDFU is the DFU specification version written to the suffix of DfuSe files
+	dfuseBcdDFU = 0x011A
+)
+
+var (
+	// dfuseSignature is the signature at the start of the prefix of a DfuSe file
+	dfuseSignature = []byte("DfuSe")
+
+	// dfuseTargetSignature is the signature at the start of the prefix of each target of a DfuSe file
+	dfuseTargetSignature = []byte("Target")
+
+	// dfuSuffixSignature is the signature of the DFU suffix, stored in reverse
+	dfuSuffixSignature = []byte("UFD")
+)
+
+// DfuSeTarget is a single target of a DfuSe file: the memory image for one alternate setting of the device's DFU interface
+type DfuSeTarget struct {
+	// AlternateSetting is the alternate setting of the DFU interface the image is written with, selecting the memory it is written to
+	AlternateSetting byte
+
+	// Name is the name of the target, or empty if the target is not named. Names longer than 254 bytes are truncated.
+	Name string
+
+	// Image is the data of the target. Each segment of the image is stored as one element of the target.
+	// A nil image is written as a target without any elements.
+	Image *Image
+}
+
+// DfuSe is the contents of a DfuSe (.dfu) file, as used by the DFU bootloaders of STM32 devices.
+// A DfuSe file holds one or more targets, and ends with a DFU suffix identifying the device it is meant for and a CRC-32 of the whole file.
+// A value of 0xFFFF for Device, Product or Vendor matches any device.
+type DfuSe struct {
+	Targets []DfuSeTarget
+	Device  uint16
+	Product uint16
+	Vendor  uint16
+}
+
+// ReadDfuSe reads a DfuSe file and creates the images of each of its targets.
+// The CRC-32 of the DFU suffix is checked along with the signatures and sizes of the prefix, targets and elements.
+// Returns the contents of the DfuSe file or an InvalidRecordError describing the first problem found.
+func ReadDfuSe(r io.Reader) (*DfuSe, error) {
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(src) < dfusePrefixSize+dfuSuffixSize {
+		return nil, dfuseError(ErrLengthMismatch, "DfuSe file must be at least %d bytes long", dfusePrefixSize+dfuSuffixSize)
+	}
+
+	suffix := src[len(src)-dfuSuffixSize:]
+	if !bytes.Equal(suffix[8:11], dfuSuffixSignature) || suffix[11] != dfuSuffixSize {
+		return nil, dfuseError(ErrBadStartCode, "DfuSe file does not end with a DFU suffix")
+	}
+
+	if crc, sum := binary.LittleEndian.Uint32(suffix[12:]), ^crc32.ChecksumIEEE(src[:len(src)-4]); crc != sum {
+		return nil, dfuseError(ErrChecksumMismatch, "DFU suffix CRC '%08X' does not match computed CRC '%08X'", crc, sum)
+	}
+
+	dfu := &DfuSe{
+		Targets: make([]DfuSeTarget, 0),
+		Device:  binary.LittleEndian.Uint16(suffix[0:]),
+		Product: binary.LittleEndian.Uint16(suffix[2:]),
+		Vendor:  binary.LittleEndian.Uint16(suffix[4:]),
+	}
+
+	if !bytes.Equal(src[:len(dfuseSignature)], dfuseSignature) || src[5] != dfuseVersion {
+		return nil, dfuseError(ErrBadStartCode, "DfuSe file must begin with the signature '%s' and version %d", dfuseSignature, dfuseVersion)
+	}
+
+	body := src[:len(src)-dfuSuffixSize]
+	if size := binary.LittleEndian.Uint32(src[6:]); uint64(size) != uint64(len(body)) {
+		return nil, dfuseError(ErrLengthMismatch, "DfuSe image size (%d) does not match actual image size (%d)", size, len(body))
+	}
+
+	targets := int(src[10])
+	body = body[dfusePrefixSize:]
+
+	for t := 0; t < targets; t++ {
+		if len(body) < dfuseTargetPrefixSize {
+			return nil, dfuseError(ErrLengthMismatch, "DfuSe target %d is shorter than its %d byte prefix", t, dfuseTargetPrefixSize)
+		}
+
+		if !bytes.Equal(body[:len(dfuseTargetSignature)], dfuseTargetSignature) {
+			return nil, dfuseError(ErrBadStartCode, "DfuSe target %d must begin with the signature '%s'", t, dfuseTargetSignature)
+		}
+
+		target := DfuSeTarget{
+			AlternateSetting: body[6],
+			Image: &Image{
+				Segments: make([]Segment, 0),
+			},
+		}
+
+		if binary.LittleEndian.Uint32(body[7:]) != 0 {
+			name := body[11 : 11+dfuseTargetNameSize]
+			if i := bytes.IndexByte(name, 0); i >= 0 {
+				name = name[:i]
+			}
+			target.Name = string(name)
+		}
+
+		size := binary.LittleEndian.Uint32(body[266:])
+		elements := binary.LittleEndian.Uint32(body[270:])
+		body = body[dfuseTargetPrefixSize:]
+
+		if uint64(size) > uint64(len(body)) {
+			return nil, dfuseError(ErrLengthMismatch, "DfuSe target %d size (%d) exceeds the remaining file size (%d)", t, size, len(body))
+		}
+
+		data := body[:size]
+		body = body[size:]
+
+		for e := uint32(0); e < elements; e++ {
+			if len(data) < dfuseElementHeaderSize {
+				return nil, dfuseError(ErrLengthMismatch, "DfuSe element %d of target %d is shorter than its %d byte header", e, t, dfuseElementHeaderSize)
+			}
+
+			address := binary.LittleEndian.Uint32(data[0:])
+			length := binary.LittleEndian.Uint32(data[4:])
+			data = data[dfuseElementHeaderSize:]
+
+			if uint64(length) > uint64(len(data)) {
+				return nil, dfuseError(ErrLengthMismatch, "DfuSe element %d of target %d size (%d) exceeds the remaining target size (%d)", e, t, length, len(data))
+			}
+
+			if uint64(address)+uint64(length) > 1<<32 {
+				return nil, dfuseError(ErrStructure, "DfuSe element %d of target %d at address %08X extends past the end of the 32 bit address space", e, t, address)
+			}
+
+			target.Image.Set(address, data[:length])
+			data = data[length:]
+		}
+
+		if len(data) != 0 {
+			return nil, dfuseError(ErrLengthMismatch, "DfuSe target %d size does not match the size of its %d elements", t, elements)
+		}
+
+		dfu.Targets = append(dfu.Targets, target)
+	}
+
+	if len(body) != 0 {
+		return nil, dfuseError(ErrStructure, "DfuSe file contains %d bytes after its last target", len(body))
+	}
+
+	return dfu, nil
+}
+
+// WriteDfuSe writes a DfuSe file to the provided writer.
+// Each segment of the image of each target is written as one element, and the DFU suffix is written with the CRC-32 of the whole file.
+// The start addresses of the images are not written, since DfuSe files have no way to store them.
+// Returns an error if the file has more than 255 targets or any errors generated by the provided writer.
+func WriteDfuSe(w io.Writer, dfu *DfuSe) error {
+
+	if len(dfu.Targets) > 255 {
+		return fmt.Errorf("DfuSe files cannot hold more than 255 targets. Targets requested: %d", len(dfu.Targets))
+	}
+
+	buf := make([]byte, dfusePrefixSize, 4096)
+	copy(buf, dfuseSignature)
+	buf[5] = dfuseVersion
+	buf[10] = byte(len(dfu.Targets))
+
+	for _, target := range dfu.Targets {
+		prefix := make([]byte, dfuseTargetPrefixSize)
+		copy(prefix, dfuseTargetSignature)
+		prefix[6] = target.AlternateSetting
+
+		if target.Name != "" {
+			binary.LittleEndian.PutUint32(prefix[7:], 1)
+			copy(prefix[11:11+dfuseTargetNameSize-1], target.Name)
+		}
+
+		segments := []Segment(nil)
+		if target.Image != nil {
+			segments = target.Image.Segments
+		}
+
+		size := 0
+		for _, seg := range segments {
+			size += dfuseElementHeaderSize + len(seg.Data)
+		}
+		binary.LittleEndian.PutUint32(prefix[266:], uint32(size))
+		binary.LittleEndian.PutUint32(prefix[270:], uint32(len(segments)))
+		buf = append(buf, prefix...)
+
+		for _, seg := range segments {
+			buf = binary.LittleEndian.AppendUint32(buf, seg.Address)
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(seg.Data)))
+			buf = append(buf, seg.Data...)
+		}
+	}
+
+	binary.LittleEndian.PutUint32(buf[6:], uint32(len(buf)))
+
+	buf = binary.LittleEndian.AppendUint16(buf, dfu.Device)
+	buf = binary.LittleEndian.AppendUint16(buf, dfu.Product)
+	buf = binary.LittleEndian.AppendUint16(buf, dfu.Vendor)
+	buf = binary.LittleEndian.AppendUint16(buf, dfuseBcdDFU)
+	buf = append(buf, dfuSuffixSignature...)
+	buf = append(buf, dfuSuffixSize)
+	buf = binary.LittleEndian.AppendUint32(buf, ^crc32.ChecksumIEEE(buf))
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// NewDfuSe creates the contents of a DfuSe file with one target for each of the provided IHEX files.
+// The target of each file uses its index in files as its alternate setting.
+// Returns the contents of the new DfuSe file or an IndexedRecordError if an address record of any file is malformed.
+func NewDfuSe(vendor uint16, product uint16, device uint16, files ...File) (*DfuSe, error) {
+
+	dfu := &DfuSe{
+		Targets: make([]DfuSeTarget, 0, len(files)),
+		Device:  device,
+		Product: product,
+		Vendor:  vendor,
+	}
+
+	for i, f := range files {
+		img, err := NewImage(f)
+		if err != nil {
+			return nil, err
+		}
+
+		dfu.Targets = append(dfu.Targets, DfuSeTarget{
+			AlternateSetting: byte(i),
+			Image:            img,
+		})
+	}
+	return dfu, nil
+}
+
+// dfuseError creates an InvalidRecordError of the specified kind for a problem found in a DfuSe file
+func dfuseError(kind error, format string, args ...interface{}) error {
+
+	return &InvalidRecordError{
+		Message: fmt.Sprintf(format, args...),
+		Kind:    kind,
+	}
+}
@@ -0,0 +1,148 @@
+package ihex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+func TestDfuSeLayout(t *testing.T) {
+
+	img := &Image{Segments: []Segment{{Address: 0x08000000, Data: []byte{1, 2, 3}}}}
+
+	var buf bytes.Buffer
+	if err := WriteDfuSe(&buf, &DfuSe{Targets: []DfuSeTarget{{AlternateSetting: 1, Name: "ST", Image: img}}, Device: 0x2200, Product: 0xDF11, Vendor: 0x0483}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	if want := dfusePrefixSize + dfuseTargetPrefixSize + dfuseElementHeaderSize + 3 + dfuSuffixSize; len(b) != want {
+		t.Fatalf("file is %d bytes, expected %d", len(b), want)
+	}
+
+	// prefix: signature, version, image size without the suffix, target count
+	if string(b[:5]) != "DfuSe" || b[5] != 1 || binary.LittleEndian.Uint32(b[6:]) != uint32(len(b)-dfuSuffixSize) || b[10] != 1 {
+		t.Fatalf("unexpected prefix % X", b[:dfusePrefixSize])
+	}
+
+	// target prefix: signature, alternate setting, named flag, name, target size, element count
+	target := b[dfusePrefixSize:]
+	if string(target[:6]) != "Target" || target[6] != 1 || binary.LittleEndian.Uint32(target[7:]) != 1 || string(target[11:14]) != "ST\x00" ||
+		binary.LittleEndian.Uint32(target[266:]) != dfuseElementHeaderSize+3 || binary.LittleEndian.Uint32(target[270:]) != 1 {
+		t.Fatalf("unexpected target prefix % X", target[:dfuseTargetPrefixSize])
+	}
+
+	// element: address, size, data
+	element := target[dfuseTargetPrefixSize:]
+	if binary.LittleEndian.Uint32(element) != 0x08000000 || binary.LittleEndian.Uint32(element[4:]) != 3 || !bytes.Equal(element[8:11], []byte{1, 2, 3}) {
+		t.Fatalf("unexpected element % X", element[:11])
+	}
+
+	// suffix: device, product, vendor, DFU version, signature, length, CRC of everything before the CRC
+	suffix := b[len(b)-dfuSuffixSize:]
+	if binary.LittleEndian.Uint16(suffix) != 0x2200 || binary.LittleEndian.Uint16(suffix[2:]) != 0xDF11 || binary.LittleEndian.Uint16(suffix[4:]) != 0x0483 ||
+		binary.LittleEndian.Uint16(suffix[6:]) != 0x011A || string(suffix[8:11]) != "UFD" || suffix[11] != dfuSuffixSize {
+		t.Fatalf("unexpected suffix % X", suffix)
+	}
+
+	if crc := binary.LittleEndian.Uint32(suffix[12:]); crc != ^crc32.ChecksumIEEE(b[:len(b)-4]) {
+		t.Fatalf("suffix CRC %08X does not match the file", crc)
+	}
+}
+
+func TestDfuSeRoundTrip(t *testing.T) {
+
+	a := &Image{Segments: []Segment{{Address: 0x08000000, Data: testData(300)}, {Address: 0x08010000, Data: testData(20)}}}
+	b := &Image{Segments: []Segment{{Address: 0x1FFF7800, Data: testData(16)}}}
+
+	want := &DfuSe{
+		Targets: []DfuSeTarget{{AlternateSetting: 0, Name: "Internal Flash", Image: a}, {AlternateSetting: 1, Image: b}},
+		Device:  0xFFFF,
+		Product: 0xDF11,
+		Vendor:  0x0483,
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDfuSe(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	dfu, err := ReadDfuSe(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dfu.Device != want.Device || dfu.Product != want.Product || dfu.Vendor != want.Vendor || len(dfu.Targets) != 2 {
+		t.Fatalf("read back %+v", dfu)
+	}
+
+	for i, target := range want.Targets {
+		if dfu.Targets[i].AlternateSetting != target.AlternateSetting || dfu.Targets[i].Name != target.Name {
+			t.Fatalf("target %d read back as %d %q", i, dfu.Targets[i].AlternateSetting, dfu.Targets[i].Name)
+		}
+		checkImage(t, dfu.Targets[i].Image, target.Image)
+	}
+}
+
+func TestDfuSeNilImage(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteDfuSe(&buf, &DfuSe{Targets: []DfuSeTarget{{}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	dfu, err := ReadDfuSe(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dfu.Targets) != 1 || len(dfu.Targets[0].Image.Segments) != 0 {
+		t.Fatalf("target without an image read back as %+v", dfu.Targets)
+	}
+}
+
+func TestReadDfuSeErrors(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteDfuSe(&buf, &DfuSe{Targets: []DfuSeTarget{{Image: &Image{Segments: []Segment{{Address: 0x100, Data: []byte{1, 2, 3, 4}}}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.Bytes()
+
+	// corrupt returns a copy of src with one byte changed and, unless keepCRC is set, the suffix CRC recomputed to match
+	corrupt := func(index int, value byte, keepCRC bool) []byte {
+		b := append([]byte(nil), src...)
+		b[index] = value
+		if !keepCRC {
+			binary.LittleEndian.PutUint32(b[len(b)-4:], ^crc32.ChecksumIEEE(b[:len(b)-4]))
+		}
+		return b
+	}
+
+	element := dfusePrefixSize + dfuseTargetPrefixSize
+
+	for _, c := range []struct {
+		name string
+		src  []byte
+		kind error
+	}{
+		{"DataWithoutCRC", corrupt(element+dfuseElementHeaderSize, 0xFF, true), ErrChecksumMismatch},
+		{"CRC", corrupt(len(src)-1, src[len(src)-1]^1, true), ErrChecksumMismatch},
+		{"Signature", corrupt(0, 'd', false), ErrBadStartCode},
+		{"SuffixSignature", corrupt(len(src)-8, 'X', false), ErrBadStartCode},
+		{"ImageSize", corrupt(6, src[6]+1, false), ErrLengthMismatch},
+		{"TargetSignature", corrupt(dfusePrefixSize, 't', false), ErrBadStartCode},
+		{"TargetSize", corrupt(dfusePrefixSize+266, src[dfusePrefixSize+266]+1, false), ErrLengthMismatch},
+		{"ElementSize", corrupt(element+4, 5, false), ErrLengthMismatch},
+		{"TargetCount", corrupt(10, 2, false), ErrLengthMismatch},
+		{"Short", src[:dfuSuffixSize], ErrLengthMismatch},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ReadDfuSe(bytes.NewReader(c.src)); !errors.Is(err, c.kind) {
+				t.Fatalf("expected %v, got %v", c.kind, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package ihex
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// documentCommentChar is the character that starts a comment line of a Document
+const documentCommentChar = ';'
+
+// LineKind defines what a single line of a Document contains
+type LineKind byte
+
+const (
+	// LineRecord is a line containing a HEX record
+	LineRecord LineKind = iota
+
+	// LineBlank is a line that is empty or only contains whitespace
+	LineBlank
+
+	// LineComment is a comment line starting with ';', as some tools emit
+	LineComment
+)
+
+// Line is a single line of a Document.
+// Text is the line exactly as it appears in the source, without its line ending.
+// Ending is the line ending that follows the line ("\n", "\r\n"), or empty if the line is the last line of the source and has no line ending.
+// Record is the parsed record of the line if Kind is LineRecord.
+type Line struct {
+	Kind   LineKind
+	Text   string
+	Ending string
+	Record Record
+}
+
+// Document is a lossless model of a HEX file.
+// It keeps every line of the source, including blank lines, comment lines, line endings and the case of hexadecimal digits.
+// Writing a Document that has not been edited reproduces the source byte for byte, and editing a record only changes the line that contains it.
+type Document struct {
+	lines []Line
+}
+
+// Len returns the number of lines in this Document
+func (me *Document) Len() int {
+	return len(me.lines)
+}
+
+// Line returns the line at the specified index
+func (me *Document) Line(index int) Line {
+	return me.lines[index]
+}
+
+// Records returns the records of this Document, in order, skipping all lines that do not contain a record
+func (me *Document) Records() []Record {
+	records, _ := me.recordPositions()
+	return records
+}
+
+// File creates an IHEX file from the records of this Document.
+// The file type is determined from the record types in the same way as NewFile.
+// Each record of the file keeps the position of its line in this Document.
+// Returns the new IHEX file or any errors encountered while adding the records.
+func (me *Document) File() (File, error) {
+	return newFileFromRecords(me.recordPositions())
+}
+
+// recordPositions returns the records of this Document, in order, along with the position of the line each one is on
+func (me *Document) recordPositions() ([]Record, []Position) {
+
+	records := make([]Record, 0, len(me.lines))
+	positions := make([]Position, 0, len(me.lines))
+	offset := int64(0)
+
+	for i, l := range me.lines {
+		if l.Kind == LineRecord {
+			records = append(records, l.Record)
+			positions = append(positions, Position{
+				Line:   i + 1,
+				Offset: offset,
+			})
+		}
+		offset += int64(len(l.Text) + len(l.Ending))
+	}
+	return records, positions
+}
+
+// SetRecord replaces the record on the line at the specified index.
+// The line is re-encoded using the hexadecimal case of the text it replaces and keeps its line ending. No other line is changed.
+// Returns an error if the index is out of range or the line does not contain a record
+func (me *Document) SetRecord(index int, r Record) error {
+
+	if index < 0 || index >= len(me.lines) {
+		return fmt.Errorf("Line index %d out of range. Document contains %d lines", index, len(me.lines))
+	}
+
+	l := &me.lines[index]
+
+	if l.Kind != LineRecord {
+		return fmt.Errorf("Line at index %d does not contain a record", index)
+	}
+
+	l.Text = encodeDocumentRecord(r, hasLowercaseHex(l.Text))
+	l.Record = r
+	return nil
+}
+
+// InsertRecord inserts a new line containing a record at the specified index, shifting the following lines back by one.
+// The new line copies the hexadecimal case of the nearest record line before it (or after it if there is none) and uses the line ending of the Document.
+// Returns an error if the index is out of range
+func (me *Document) InsertRecord(index int, r Record) error {
+
+	if index < 0 || index > len(me.lines) {
+		return fmt.Errorf("Line index %d out of range. Document contains %d lines", index, len(me.lines))
+	}
+
+	lowercase := false
+	ending := me.lineEnding()
+
+	if n := me.nearestRecordLine(index); n >= 0 {
+		lowercase = hasLowercaseHex(me.lines[n].Text)
+	}
+
+	// the line being inserted after the last line of a source without a final line ending needs one before it
+	if index == len(me.lines) && index > 0 && me.lines[index-1].Ending == "" {
+		me.lines[index-1].Ending = ending
+		ending = ""
+	}
+
+	l := Line{
+		Kind:   LineRecord,
+		Text:   encodeDocumentRecord(r, lowercase),
+		Ending: ending,
+		Record: r,
+	}
+
+	me.lines = append(me.lines, Line{})
+	copy(me.lines[index+1:], me.lines[index:])
+	me.lines[index] = l
+	return nil
+}
+
+// RemoveLine removes the line at the specified index, shifting the following lines forward by one.
+// Returns an error if the index is out of range
+func (me *Document) RemoveLine(index int) error {
+
+	if index < 0 || index >= len(me.lines) {
+		return fmt.Errorf("Line index %d out of range. Document contains %d lines", index, len(me.lines))
+	}
+
+	me.lines = append(me.lines[:index], me.lines[index+1:]...)
+	return nil
+}
+
+// WriteTo writes every line of this Document, with its original line ending, to w.
+// Returns the number of bytes written and any errors generated by the writer.
+func (me *Document) WriteTo(w io.Writer) (int64, error) {
+
+	sum := int64(0)
+
+	for _, l := range me.lines {
+		n, err := io.WriteString(w, l.Text+l.Ending)
+		sum += int64(n)
+
+		if err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+// lineEnding returns the line ending used by the first line of this Document that has one, or "\n" if no line has one
+func (me *Document) lineEnding() string {
+
+	for _, l := range me.lines {
+		if l.Ending != "" {
+			return l.Ending
+		}
+	}
+	return "\n"
+}
+
+// nearestRecordLine returns the index of the closest record line before index, or after it if there is none.
+// Returns -1 if this Document contains no records.
+func (me *Document) nearestRecordLine(index int) int {
+
+	for i := index - 1; i >= 0; i-- {
+		if me.lines[i].Kind == LineRecord {
+			return i
+		}
+	}
+
+	for i := index; i < len(me.lines); i++ {
+		if me.lines[i].Kind == LineRecord {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewDocument reads the provided reader and creates a lossless Document of its contents.
+// Blank lines and lines starting with ';' are kept as blank and comment lines. Every other line is parsed as a record,
+// so a line that is neither, such as a record indented with whitespace, is an error rather than a line that is silently left out of the records.
+// Returns the new Document or an IndexedRecordError if any record is formatted incorrectly.
+func NewDocument(r io.Reader) (*Document, error) {
+	return NewDocumentWithOptions(r, ParseOptions{})
+}
+
+// NewDocumentWithOptions is equivalent to NewDocument, using the provided options to limit the input read.
+// The whole input is held in memory, so MaxInputBytes should be set when reading from an untrusted source.
+// The record limits and RejectDataAfterEOF apply to the record lines only. Concurrency and ChunkSize are ignored.
+// Returns the new Document, a LimitExceededError if any limit is crossed, or an IndexedRecordError if any record is formatted incorrectly.
+func NewDocumentWithOptions(r io.Reader, opts ParseOptions) (*Document, error) {
+
+	cr := newCountingReader(r, opts)
+
+	src, err := io.ReadAll(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		lines: make([]Line, 0, bytes.Count(src, []byte{'\n'})+1),
+	}
+
+	buf := make([]byte, 0, recordMaximumDataSize+recordHeaderAndChecksumSize)
+	index := 0
+	limits := parseLimits{opts: opts}
+	offset := int64(0)
+
+	for len(src) > 0 {
+
+		text := src
+		ending := ""
+
+		if i := bytes.IndexByte(src, '\n'); i >= 0 {
+			text = src[:i]
+			ending = "\n"
+			src = src[i+1:]
+
+			if len(text) > 0 && text[len(text)-1] == '\r' {
+				text = text[:len(text)-1]
+				ending = "\r\n"
+			}
+		} else {
+			src = nil
+		}
+
+		l := Line{
+			Text:   string(text),
+			Ending: ending,
+		}
+
+		if len(bytes.TrimSpace(text)) == 0 {
+			l.Kind = LineBlank
+		} else if text[0] == documentCommentChar {
+			l.Kind = LineComment
+		} else {
+			record, b, err := parseRecord(text, buf)
+			buf = b
+
+			if err == nil {
+				err = setRecordErrorText(limits.add(record), text)
+			}
+
+			if err != nil {
+				return nil, &IndexedRecordError{
+					Index:       index,
+					RecordError: setRecordErrorLine(err, len(doc.lines)+1),
+				}
+			}
+
+			record.Data = append(make([]byte, 0, len(record.Data)), record.Data...)
+
+			l.Kind = LineRecord
+			l.Record = record
+			index++
+		}
+
+		doc.lines = append(doc.lines, l)
+		offset += int64(len(text) + len(ending))
+
+		if l.Kind == LineRecord && opts.Progress != nil {
+			opts.Progress(index, offset)
+		}
+	}
+
+	return doc, nil
+}
+
+// encodeDocumentRecord encodes a record as the text of a Document line, without a line ending
+func encodeDocumentRecord(r Record, lowercase bool) string {
+
+	if lowercase {
+		return string(r.appendTo(nil, recordLowerHexDigits, ""))
+	}
+	return string(r.appendTo(nil, recordHexDigits, ""))
+}
+
+// hasLowercaseHex returns true if the text of a record line uses lowercase hexadecimal digits
+func hasLowercaseHex(text string) bool {
+	return strings.ContainsAny(text, "abcdef")
+}
@@ -0,0 +1,127 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewDocumentWithOptionsLimits(t *testing.T) {
+
+	src := string(benchmarkHex(t, 1024))
+
+	for _, c := range []struct {
+		name  string
+		opts  ParseOptions
+		limit string
+	}{
+		{"MaxInputBytes", ParseOptions{MaxInputBytes: 100}, "MaxInputBytes"},
+		{"MaxRecords", ParseOptions{MaxRecords: 3}, "MaxRecords"},
+		{"MaxDataBytes", ParseOptions{MaxDataBytes: 100}, "MaxDataBytes"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewDocumentWithOptions(strings.NewReader(src), c.opts)
+
+			var e *LimitExceededError
+			if !errors.As(err, &e) || e.Limit != c.limit {
+				t.Fatalf("expected %s to be exceeded, got %v", c.limit, err)
+			}
+		})
+	}
+
+	doc, err := NewDocumentWithOptions(strings.NewReader(src), ParseOptions{MaxInputBytes: int64(len(src)), MaxRecords: 34, MaxDataBytes: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != src {
+		t.Fatal("document read within its limits does not reproduce its source")
+	}
+}
+
+// documentSource is a HEX file with CRLF line endings, lowercase and uppercase records, blank lines, comments and no final line ending
+const documentSource = "; generated by a vendor tool\r\n:020000040800f2\r\n\r\n:0400000001020304f2\r\n  \r\n; data\r\n:04000400AABBCCDDEA\r\n:00000001FF"
+
+func TestDocumentRoundTrip(t *testing.T) {
+
+	doc, err := NewDocument(strings.NewReader(documentSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Len() != 8 || len(doc.Records()) != 4 {
+		t.Fatalf("read %d lines and %d records, expected 8 lines and 4 records", doc.Len(), len(doc.Records()))
+	}
+
+	for i, kind := range []LineKind{LineComment, LineRecord, LineBlank, LineRecord, LineBlank, LineComment, LineRecord, LineRecord} {
+		if doc.Line(i).Kind != kind {
+			t.Fatalf("line %d has kind %d, expected %d", i, doc.Line(i).Kind, kind)
+		}
+	}
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != documentSource {
+		t.Fatalf("document written as %q, expected %q", out.String(), documentSource)
+	}
+}
+
+func TestDocumentSetRecord(t *testing.T) {
+
+	doc, err := NewDocument(strings.NewReader(documentSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.SetRecord(3, Record{Type: RecordData, AddressOffset: 0, Data: []byte{0x01, 0x02, 0x03, 0xFF}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.SetRecord(0, Record{Type: RecordEOF}); err == nil {
+		t.Fatal("SetRecord replaced a comment line")
+	}
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	// the edited line keeps its lowercase digits and CRLF ending, and no other line changes
+	got := strings.Split(out.String(), "\n")
+	want := strings.Split(documentSource, "\n")
+
+	for i := range want {
+		if i == 3 {
+			if got[i] != ":04000000010203fff7\r" {
+				t.Fatalf("edited line written as %q", got[i])
+			}
+		} else if got[i] != want[i] {
+			t.Fatalf("line %d changed from %q to %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestNewDocumentRejectsNonRecordLines(t *testing.T) {
+
+	for _, src := range []string{
+		":00000001FF\n :00000001FF\n",
+		":00000001FF\ngarbage\n",
+		"# not a comment\n:00000001FF\n",
+	} {
+		_, err := NewDocument(strings.NewReader(src))
+
+		var e *InvalidRecordError
+		if !errors.As(err, &e) || !errors.Is(err, ErrBadStartCode) {
+			t.Errorf("%q: expected a bad start code, got %v", src, err)
+		}
+	}
+}
@@ -1,6 +1,41 @@
 package ihex
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The kinds of problems that can be found in a HEX record or file.
+// Every error returned while reading a HEX file matches one of these with errors.Is.
+var (
+	// ErrBadStartCode indicates a record does not begin with the ':' start code
+	ErrBadStartCode = errors.New("bad start code")
+
+	// ErrBadHexDigit indicates a record contains a character that is not a hexadecimal digit, or an odd number of digits
+	ErrBadHexDigit = errors.New("bad hexadecimal digit")
+
+	// ErrLengthMismatch indicates a record's byte count does not match the number of bytes in the record
+	ErrLengthMismatch = errors.New("length mismatch")
+
+	// ErrChecksumMismatch indicates a record's checksum does not match the checksum computed from its contents
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrRecordTooLong indicates a record is longer than the maximum record size
+	ErrRecordTooLong = errors.New("record too long")
+
+	// ErrInvalidRecordType indicates a record's type is not valid for the HEX file format the record was found in
+	ErrInvalidRecordType = errors.New("invalid record type for file type")
+
+	// ErrStructure indicates a record is well formed on its own, but breaks the rules for how records fit together in a file
+	ErrStructure = errors.New("structural violation")
+
+	// ErrLimitExceeded indicates a file exceeds one of the resource limits set in its ParseOptions
+	ErrLimitExceeded = errors.New("limit exceeded")
+
+	// ErrUnknownFormat indicates Open could not detect the format of its input with any registered Format
+	ErrUnknownFormat = errors.New("unknown file format")
+)
 
 // InvalidRecordTypeError error indicating a record type is incompatible with the HEX file format the record was found in
 type InvalidRecordTypeError struct {
@@ -13,16 +48,92 @@ func (me *InvalidRecordTypeError) Error() string {
 	return fmt.Sprintf("Record Type %02X is not valid for I%dHEX files", byte(me.InvalidRecordType), int(me.InvaildFileType))
 }
 
-// InvalidRecordError error indicating that a HEX record is formatted incorrectly in some way
+// Is reports whether target is ErrInvalidRecordType, so this error can be checked with errors.Is
+func (me *InvalidRecordTypeError) Is(target error) bool {
+	return target == ErrInvalidRecordType
+}
+
+// InvalidRecordError error indicating that a HEX record is formatted incorrectly in some way.
+// Kind is one of the Err* values of this package and is matched by errors.Is.
+// Line and Column are 1-based positions of the problem in the source, or 0 if unknown.
+// Text is the offending line of the source, or empty if unknown.
 type InvalidRecordError struct {
 	Message string
+	Kind    error
+	Line    int
+	Column  int
+	Text    string
 }
 
 // Error returns the error message for this error
 func (me *InvalidRecordError) Error() string {
+	if me.Line > 0 && me.Column > 0 {
+		return fmt.Sprintf("Record formatted incorrectly at line %d, column %d: %s", me.Line, me.Column, me.Message)
+	} else if me.Line > 0 {
+		return fmt.Sprintf("Record formatted incorrectly at line %d: %s", me.Line, me.Message)
+	}
 	return fmt.Sprintf("Record formatted incorrectly: %s", me.Message)
 }
 
+// Unwrap returns the kind of this error
+func (me *InvalidRecordError) Unwrap() error {
+	return me.Kind
+}
+
+// Diagnostic renders this error for display on a terminal.
+// The message is followed by the offending line of text and a caret pointing at the column of the problem, if they are known.
+func (me *InvalidRecordError) Diagnostic() string {
+
+	var b strings.Builder
+
+	if me.Line > 0 && me.Column > 0 {
+		fmt.Fprintf(&b, "line %d, column %d: ", me.Line, me.Column)
+	} else if me.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", me.Line)
+	}
+	b.WriteString(me.Message)
+
+	if me.Text != "" && me.Column > 0 {
+		fmt.Fprintf(&b, "\n%s\n%s^", me.Text, strings.Repeat(" ", me.Column-1))
+	}
+
+	return b.String()
+}
+
+// newInvalidRecordError creates an InvalidRecordError of the specified kind pointing at a column of a line of text
+func newInvalidRecordError(kind error, column int, text []byte, format string, args ...interface{}) *InvalidRecordError {
+	return &InvalidRecordError{
+		Message: fmt.Sprintf(format, args...),
+		Kind:    kind,
+		Column:  column,
+		Text:    string(text),
+	}
+}
+
+// LimitExceededError error indicating that reading a file was stopped because it exceeds one of the resource limits in its ParseOptions.
+// Limit is the name of the ParseOptions field that was exceeded.
+type LimitExceededError struct {
+	Limit string
+	Max   int64
+}
+
+// Error returns the error message for this error
+func (me *LimitExceededError) Error() string {
+	return fmt.Sprintf("Parse limit %s of %d exceeded", me.Limit, me.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded, so this error can be checked with errors.Is
+func (me *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// newLineError creates an InvalidRecordError of the specified kind pointing at a column of a numbered line of text
+func newLineError(kind error, line int, column int, text []byte, format string, args ...interface{}) *InvalidRecordError {
+	err := newInvalidRecordError(kind, column, text, format, args...)
+	err.Line = line
+	return err
+}
+
 // IndexedRecordError an error that occurred at a particular record index
 type IndexedRecordError struct {
 	RecordError error
@@ -33,3 +144,8 @@ type IndexedRecordError struct {
 func (me *IndexedRecordError) Error() string {
 	return fmt.Sprintf("Error occurred on record at index %d: %s", me.Index, me.RecordError.Error())
 }
+
+// Unwrap returns the error that occurred on the record
+func (me *IndexedRecordError) Unwrap() error {
+	return me.RecordError
+}
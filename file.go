@@ -4,25 +4,44 @@
 package ihex
 
 import (
-	"bufio"
+	"compress/gzip"
+	"context"
 	"encoding/binary"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 )
 
+// fileDataArenaSize is the size of each block of storage allocated to hold record data while reading a file.
+// Record data is packed into these blocks instead of being allocated one record at a time.
+const fileDataArenaSize = 64 * 1024
+
 // File is the base interface for all IHEX file formats.
 // All IHEX files must be able to do the following:
 // Provide their file type.
 // Read each record one at a time.
 // Reset reading to the starting record of the file.
 // Add one or more record(s) to the file.
+// Insert and remove records at any position in the file.
+// Provide the number of records and the records themselves, as a list or an iterator.
+// Create an independent copy of the file.
+// Provide the source position of each record and find the record containing an address.
+// RecordFile implements all of this for every file type.
 type File interface {
 	GetType() FileType
 	ReadNext() (Record, bool)
 	Reset()
 	Add(r Record) error
 	AddRecords(r ...Record) error
+	Len() int
+	Records() []Record
+	All() iter.Seq2[int, Record]
+	Insert(index int, r Record) error
+	Remove(index int) error
+	Clone() File
+	Position(index int) (Position, bool)
+	FindAddress(address uint32) (int, bool)
 }
 
 // WriteFile resets an IHEX file to the beginning record.
@@ -30,121 +49,285 @@ type File interface {
 // This also automatically handles creating checksums for each record in the file based on the record data.
 // Returns any errors generated by the provided writer during the writing process.
 func WriteFile(f File, w io.Writer) error {
+	return WriteFileContext(context.Background(), f, w)
+}
+
+// WriteFileContext is equivalent to WriteFile, but stops writing and returns the context's error if ctx is cancelled.
+// The context is checked before each record is written.
+func WriteFileContext(ctx context.Context, f File, w io.Writer) error {
+	return WriteFileWithOptions(ctx, f, w, WriteOptions{})
+}
+
+// WriteFileWithOptions is equivalent to WriteFileContext, using the provided options to control the formatting of the output.
+func WriteFileWithOptions(ctx context.Context, f File, w io.Writer, opts WriteOptions) error {
 
 	f.Reset()
 
+	if opts.Gzip {
+		gz := gzip.NewWriter(w)
+		opts.Gzip = false
+
+		if err := WriteFileWithOptions(ctx, f, gz, opts); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+
+	writer := NewRecordWriterOptions(w, opts)
+	sum := int64(0)
+
 	i := 1
 	for r, ok := f.ReadNext(); ok; r, ok = f.ReadNext() {
-		if _, err := r.write(w); err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if opts.skip(r) {
+			continue
+		}
+
+		n, err := writer.WriteRecord(r)
+		if err != nil {
 			return fmt.Errorf("Error writing file at record %d: %s", i, err.Error())
 		}
+		sum += int64(n)
+
+		if opts.Progress != nil {
+			opts.Progress(i, sum)
+		}
 		i++
 	}
 
-	return nil
+	if opts.EOF == EOFEnforceSingle {
+		if _, err := writer.WriteRecord(Record{Type: RecordEOF, Data: make([]byte, 0)}); err != nil {
+			return fmt.Errorf("Error writing file at record %d: %s", i, err.Error())
+		}
+	}
+
+	return writer.Flush()
 }
 
 // NewFile reads the provided reader and creates an IHEX file based on the data.
 // This automatically determines the IHEX file format based on the record types being read.
+// Input compressed with gzip or bzip2 is detected from its magic number and decompressed as it is read.
 // Returns the new IHEX file generated from the reader data or and error if any errors were encountered during reading.
 func NewFile(r io.Reader) (File, error) {
+	return NewFileContext(context.Background(), r)
+}
+
+// NewFileContext is equivalent to NewFile, but stops reading and returns the context's error if ctx is cancelled.
+// The context is checked before each record is read.
+func NewFileContext(ctx context.Context, r io.Reader) (File, error) {
+	return NewFileWithOptions(ctx, r, ParseOptions{})
+}
+
+// NewFileWithOptions is equivalent to NewFileContext, using the provided options to control reading.
+func NewFileWithOptions(ctx context.Context, r io.Reader, opts ParseOptions) (File, error) {
+
+	records, positions, err := readAllRecords(ctx, r, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileFromRecords(records, positions)
+}
+
+// readAllRecords reads every record from the reader, concurrently if the options allow it.
+// Returns the records read and their positions in the source, or any errors encountered during reading.
+func readAllRecords(ctx context.Context, r io.Reader, opts ParseOptions) ([]Record, []Position, error) {
+
+	cr := newCountingReader(newDecompressReader(r), opts)
+
+	if opts.Concurrency > 1 {
+		return readRecordsConcurrent(ctx, cr, opts)
+	}
+	return readRecords(ctx, cr, opts)
+}
+
+// readRecords reads every record from the reader one at a time.
+// Returns the records read and their positions in the source, or any errors encountered during reading.
+func readRecords(ctx context.Context, cr *countingReader, opts ParseOptions) ([]Record, []Position, error) {
 
-	currFileType := I8HEX
 	records := make([]Record, 0)
+	positions := make([]Position, 0)
+	arena := make([]byte, 0, fileDataArenaSize)
 
-	scanner := bufio.NewScanner(r)
+	reader := newRecordReader(cr, opts)
 
-	i := 0
-	for scanner.Scan() {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 
-		r, err := parseRecord(scanner.Text())
+		r, err := reader.Read()
 
-		if err != nil {
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		// the record reader reuses its buffer, so copy the data into a shared block of storage that outlives it
+		if cap(arena)-len(arena) < len(r.Data) {
+			arena = make([]byte, 0, fileDataArenaSize)
+		}
+		start := len(arena)
+		arena = append(arena, r.Data...)
+		r.Data = arena[start:len(arena):len(arena)]
+
+		records = append(records, r)
+		positions = append(positions, reader.Position())
+
+		if opts.Progress != nil {
+			opts.Progress(len(records), cr.count)
+		}
+	}
+
+	return records, positions, nil
+}
+
+// newFileFromRecords creates an IHEX file containing the provided records, in order.
+// The file format is determined by the record types present and the extended addresses are resolved to check that the address records are well formed.
+// positions holds the source position of each record, or is nil if the records have no source.
+// Returns the new IHEX file or an IndexedRecordError for the first record that could not be added.
+// Records of a type that is not valid for the detected file format are reported as an InvalidRecordError of kind ErrInvalidRecordType pointing at the record's type.
+func newFileFromRecords(records []Record, positions []Position) (File, error) {
+
+	currFileType := I8HEX
+
+	for _, r := range records {
+		if r.Type == RecordExtSegment || r.Type == RecordStartSegment {
+			currFileType = I16HEX
+			break
+		} else if r.Type == RecordExtLinear || r.Type == RecordStartLinear {
+			currFileType = I32HEX
+			break
+		}
+	}
+
+	addresses := addressState{}
+
+	f, rf := newFileOfType(currFileType)
+
+	for i, r := range records {
+		pos := Position{}
+		if positions != nil {
+			pos = positions[i]
+		}
+
+		if _, err := addresses.next(r); err != nil {
 			return nil, &IndexedRecordError{
 				Index:       i,
-				RecordError: err,
+				RecordError: setRecordErrorText(setRecordErrorLine(err, pos.Line), recordErrorText(r)),
 			}
 		}
 
-		if currFileType == I8HEX {
-			if r.Type == RecordExtSegment || r.Type == RecordStartSegment {
-				currFileType = I16HEX
-			} else if r.Type == RecordExtLinear || r.Type == RecordStartLinear {
-				currFileType = I32HEX
+		if err := rf.insert(i, r, pos); err != nil {
+			if errors.Is(err, ErrInvalidRecordType) {
+				err = newLineError(ErrInvalidRecordType, pos.Line, recordRecordTypeIndex*2+2, recordErrorText(r), "%s", err.Error())
 			}
-		}
 
-		records = append(records, r)
+			return nil, &IndexedRecordError{
+				Index:       i,
+				RecordError: err,
+			}
+		}
 	}
 
-	var f File
+	return f, nil
+}
 
-	if currFileType == I16HEX {
-		f = NewI16HEXFile()
-	} else if currFileType == I32HEX {
-		f = NewI32HEXFile()
-	} else {
-		f = NewI8HEXFile()
+// newFileOfType creates a new, empty IHEX file of the specified file type.
+// Any file type other than I16HEX and I32HEX creates an I8HEX file.
+// Returns the new IHEX file along with the RecordFile that backs it.
+func newFileOfType(fileType FileType) (File, *RecordFile) {
+
+	if fileType == I16HEX {
+		f := NewI16HEXFile()
+		return f, &f.RecordFile
+	} else if fileType == I32HEX {
+		f := NewI32HEXFile()
+		return f, &f.RecordFile
 	}
 
-	err := f.AddRecords(records...)
-
-	return f, err
+	f := NewI8HEXFile()
+	return f, &f.RecordFile
 }
 
 // parseRecord attempts to parse a line into a Record.
-// Returns the newly read record or any errors encountered during parsing.
-func parseRecord(line string) (Record, error) {
+// The decoded record bytes are stored in buf, which is grown if it is too small, so that the caller can reuse it between records.
+// The returned record's Data refers to the returned buffer and is only valid until the buffer is reused.
+// Returns the newly read record, the (possibly grown) buffer, or an InvalidRecordError describing where the line is malformed.
+// The Line of the returned error is left for the caller to fill in.
+func parseRecord(line []byte, buf []byte) (Record, []byte, error) {
 
 	record := Record{}
 
 	if len(line) > recordMaximumSizeChars {
-		return record, &InvalidRecordError{
-			Message: fmt.Sprintf("Maximum record size is %d bytes. Record size detected: %d bytes", (recordMaximumSizeChars-1)/2, len(line)/2),
-		}
+		return record, buf, newInvalidRecordError(ErrRecordTooLong, recordMaximumSizeChars+1, line, "Maximum record size is %d bytes. Record size detected: %d bytes", (recordMaximumSizeChars-1)/2, len(line)/2)
+	}
+
+	if len(line) == 0 {
+		return record, buf, newInvalidRecordError(ErrBadStartCode, 1, line, "HEX record is empty")
 	}
 
 	if line[0] != recordStartChar {
-		return record, &InvalidRecordError{
-			Message: fmt.Sprintf("HEX record must begin with '%c'. Record starts with: '%c'", recordStartChar, line[0]),
-		}
+		return record, buf, newInvalidRecordError(ErrBadStartCode, 1, line, "HEX record must begin with '%c'. Record starts with: '%c'", recordStartChar, line[0])
 	}
 
-	recordBytes, err := hex.DecodeString(line[1:])
+	recordBytes, pos, err := decodeHex(buf[:0], line[1:])
+	buf = recordBytes
 
 	if err != nil {
-		return record, &InvalidRecordError{
-			Message: fmt.Sprintf("Unable to decode hexadecimal record contents: %s", err.Error()),
-		}
+		return record, buf, newInvalidRecordError(ErrBadHexDigit, pos+2, line, "Unable to decode hexadecimal record contents: %s", err.Error())
+	}
+
+	if len(recordBytes) < recordHeaderAndChecksumSize {
+		return record, buf, newInvalidRecordError(ErrLengthMismatch, len(line)+1, line, "Minimum record size is %d bytes. Record size detected: %d bytes", recordHeaderAndChecksumSize, len(recordBytes))
 	}
 
 	dataSize := int(recordBytes[recordByteCountIndex])
 	actualDataSize := len(recordBytes) - recordHeaderAndChecksumSize
 
 	if dataSize != actualDataSize {
-		return record, &InvalidRecordError{
-			Message: fmt.Sprintf("Record byte count (%d) does not match actual detected byte count (%d)", dataSize, actualDataSize),
-		}
+		return record, buf, newInvalidRecordError(ErrLengthMismatch, recordByteCountIndex*2+2, line, "Record byte count (%d) does not match actual detected byte count (%d)", dataSize, actualDataSize)
 	}
 
-	record.AddressOffset = binary.BigEndian.Uint16(recordBytes[recordAddressByteIndex:2])
+	record.AddressOffset = binary.BigEndian.Uint16(recordBytes[recordAddressByteIndex:recordRecordTypeIndex])
 	record.Type = RecordType(recordBytes[recordRecordTypeIndex])
+	record.Data = recordBytes[recordDataIndex : recordDataIndex+dataSize]
 
-	if dataSize > 0 {
-		record.Data = recordBytes[recordDataIndex:dataSize]
+	checksum := recordBytes[recordDataIndex+dataSize]
+	computedChecksum := record.getChecksum()
 
-		checksum := recordBytes[recordDataIndex+dataSize]
+	if checksum != computedChecksum {
+		return record, buf, newInvalidRecordError(ErrChecksumMismatch, (recordDataIndex+dataSize)*2+2, line, "Record checksum '%02X' does not match computed checksum '%02X'", checksum, computedChecksum)
+	}
 
-		computedChecksum := record.getChecksum()
+	return record, buf, nil
+}
 
-		if checksum != computedChecksum {
-			return record, &InvalidRecordError{
-				Message: fmt.Sprintf("Record checksum '%02X' does not match computed checksum '%02X'", checksum, computedChecksum),
-			}
-		}
-	} else {
-		record.Data = make([]byte, 0)
+// setRecordErrorLine sets the line number of err if it is an InvalidRecordError.
+// Returns err
+func setRecordErrorLine(err error, line int) error {
+	if e, ok := err.(*InvalidRecordError); ok {
+		e.Line = line
 	}
+	return err
+}
+
+// setRecordErrorText sets the offending line of err to text if it is an InvalidRecordError that does not have one, so that its Diagnostic can point at the problem.
+// Returns err
+func setRecordErrorText(err error, text []byte) error {
+	if e, ok := err.(*InvalidRecordError); ok && e.Text == "" {
+		e.Text = string(text)
+	}
+	return err
+}
 
-	return record, nil
+// recordErrorText returns the text to show with an error found in a record whose source line was not kept after it was parsed.
+// The record is encoded again, so the text only differs from the source line in the case of its hexadecimal digits.
+func recordErrorText(r Record) []byte {
+	return r.appendTo(nil, recordHexDigits, "")
 }
@@ -0,0 +1,235 @@
+package ihex
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultParseChunkSize is the number of lines handed to each goroutine at a time when parsing concurrently
+const defaultParseChunkSize = 4096
+
+// parseChunk is a block of consecutive lines read from a HEX file, waiting to be decoded
+type parseChunk struct {
+	sequence  int
+	first     int
+	data      []byte
+	ends      []int
+	offsets   []int64
+	bytesRead int64
+}
+
+// parsedChunk is the result of decoding a parseChunk
+type parsedChunk struct {
+	sequence  int
+	records   []Record
+	positions []Position
+	err       error
+	bytesRead int64
+}
+
+// parse decodes every line in this chunk into a record.
+// The data of every record is stored in a single block of storage owned by the chunk.
+// Returns the decoded records, or the first error encountered along with its record index.
+func (me parseChunk) parse(ctx context.Context) parsedChunk {
+
+	result := parsedChunk{
+		sequence:  me.sequence,
+		records:   make([]Record, 0, len(me.ends)),
+		positions: make([]Position, len(me.ends)),
+		bytesRead: me.bytesRead,
+	}
+
+	// a record's data is always less than half of its line length, so the arena never needs to grow
+	arena := make([]byte, 0, len(me.data)/2)
+	buf := make([]byte, 0, recordMaximumDataSize+recordHeaderAndChecksumSize)
+
+	start := 0
+	for i, end := range me.ends {
+		if err := ctx.Err(); err != nil {
+			result.err = err
+			return result
+		}
+
+		r, b, err := parseRecord(me.data[start:end], buf)
+		buf = b
+		start = end
+
+		if err != nil {
+			result.err = &IndexedRecordError{
+				Index:       me.first + i,
+				RecordError: setRecordErrorLine(err, me.first+i+1),
+			}
+			return result
+		}
+
+		n := len(arena)
+		arena = append(arena, r.Data...)
+		r.Data = arena[n:len(arena):len(arena)]
+
+		result.records = append(result.records, r)
+		result.positions[i] = Position{
+			Line:   me.first + i + 1,
+			Offset: me.offsets[i],
+		}
+	}
+
+	return result
+}
+
+// readRecordsConcurrent reads every record from the reader, splitting the lines into chunks that are decoded by a pool of goroutines.
+// The decoded chunks are stitched back together in their original order.
+// Returns the records read and their positions in the source, or the first error encountered in file order.
+func readRecordsConcurrent(ctx context.Context, cr *countingReader, opts ParseOptions) ([]Record, []Position, error) {
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultParseChunkSize
+	}
+
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan parseChunk, opts.Concurrency)
+	results := make(chan parsedChunk, opts.Concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				results <- c.parse(workCtx)
+			}
+		}()
+	}
+
+	var readErr error
+
+	go func() {
+		defer close(chunks)
+
+		scanner := newLineScanner(cr)
+
+		c := parseChunk{}
+		lines := 0
+		dataBytes := int64(0)
+
+		send := func() bool {
+			c.bytesRead = cr.count
+			select {
+			case chunks <- c:
+			case <-workCtx.Done():
+				return false
+			}
+			c = parseChunk{
+				sequence: c.sequence + 1,
+				first:    lines,
+			}
+			return true
+		}
+
+		for scanner.Scan() {
+			// the scanner still returns the last partial line after a read error, which must not be parsed as a truncated record
+			if scanner.Err() != nil {
+				break
+			}
+
+			line := scanner.Bytes()
+			c.data = append(c.data, line...)
+			c.ends = append(c.ends, len(c.data))
+			c.offsets = append(c.offsets, scanner.Offset())
+			lines++
+
+			// the data bytes of a well formed record are known from its length before it is decoded
+			if n := len(line) - 1 - recordHeaderAndChecksumSize*2; n > 0 {
+				dataBytes += int64(n / 2)
+			}
+
+			// once a line crosses the record or data limits, the lines after it are never needed.
+			// The chunk holding it is still decoded so that the limit is reported in file order, after any earlier errors.
+			if opts.MaxRecords > 0 && int64(lines) > opts.MaxRecords || opts.MaxDataBytes > 0 && dataBytes > opts.MaxDataBytes {
+				send()
+				return
+			}
+
+			if len(c.ends) >= chunkSize && !send() {
+				return
+			}
+		}
+
+		if readErr = scanner.lineErr(lines); readErr == nil && len(c.ends) > 0 {
+			send()
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	records := make([]Record, 0)
+	positions := make([]Position, 0)
+	limits := parseLimits{opts: opts}
+	pending := make(map[int]parsedChunk)
+	next := 0
+
+	var firstErr error
+
+	for result := range results {
+		if firstErr != nil {
+			continue
+		}
+
+		pending[result.sequence] = result
+
+		for p, ok := pending[next]; ok; p, ok = pending[next] {
+			delete(pending, next)
+			next++
+
+			// chunks decoded before the context was cancelled are dropped rather than added and reported
+			if err := ctx.Err(); err != nil {
+				firstErr = err
+				break
+			}
+
+			if p.err != nil {
+				firstErr = p.err
+				cancel()
+				break
+			}
+
+			for i, r := range p.records {
+				if err := limits.add(r); err != nil {
+					p.err = &IndexedRecordError{
+						Index:       len(records) + i,
+						RecordError: setRecordErrorText(setRecordErrorLine(err, len(records)+i+1), recordErrorText(r)),
+					}
+					break
+				}
+			}
+
+			if p.err != nil {
+				firstErr = p.err
+				cancel()
+				break
+			}
+
+			records = append(records, p.records...)
+			positions = append(positions, p.positions...)
+
+			if opts.Progress != nil {
+				opts.Progress(len(records), p.bytesRead)
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	} else if firstErr != nil {
+		return nil, nil, firstErr
+	} else if readErr != nil {
+		return nil, nil, readErr
+	}
+
+	return records, positions, nil
+}
@@ -17,3 +17,8 @@ const (
 	// The I32HEX file format supports up to 32 bit memory addresses.
 	I32HEX FileType = 32
 )
+
+// isValid returns true if this file type is one of I8HEX, I16HEX or I32HEX
+func (me FileType) isValid() bool {
+	return me == I8HEX || me == I16HEX || me == I32HEX
+}
@@ -1,6 +1,7 @@
 package ihex
 
 import (
+	"compress/gzip"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -8,53 +9,178 @@ import (
 )
 
 const (
-	writerMaximumI8HEXRecords  int64 = 65536
-	writerMaximumI16HEXRecords int64 = 1048576
-	writerMaximumI32HEXRecords int64 = 4294967296
+	// The size of the address space (in bytes) that each HEX file type can address
+	writerI8HEXAddressSpace  uint64 = 0x10000
+	writerI16HEXAddressSpace uint64 = 0x100000
+	writerI32HEXAddressSpace uint64 = 0x100000000
+
+	// writerSegmentSize is the number of bytes addressable by a record's 16 bit address offset
+	writerSegmentSize uint64 = 0x10000
 )
 
+// recordSink is the destination of the records generated by a FileWriter
+type recordSink interface {
+	WriteRecord(r Record) (int, error)
+	Flush() error
+}
+
 // FileWriter writes a stream of bytes into HEX file format.
-// The data is organized into records of fixed width with continuously incrementing addresses.
+// The data is organized into records of fixed width with continuously incrementing addresses, starting at address 0.
+// Extended address records are written automatically whenever the address crosses a 64 KiB boundary.
+// Records never cross a 64 KiB boundary, so a record that would is split in two at the boundary.
+// By default the final record only contains the bytes actually written, so it may be shorter than the other records.
+// Encoded records are buffered according to the BufferSize write option, and only reach the underlying writer when the buffer fills or Flush or Close is called.
 type FileWriter struct {
 	recordSize  int
-	recordCount int64
+	address     uint64
+	base        uint64
 	buffer      []byte
 	bufferIndex int
 	fileType    FileType
 	writer      io.Writer
+	records     recordSink
+	compressor  *gzip.Writer
 	closed      bool
+	padFinal    bool
+	padByte     byte
+	closeWriter bool
+	consumed    int64
+	produced    int64
+	written     int
+	unit        uint64
+	opts        WriteOptions
+}
+
+// SetPadding configures how the final partial record is written when this FileWriter is closed.
+// If enabled is true, the final record is filled up to the full record size with padByte.
+// If enabled is false (the default), the final record only contains the bytes actually written.
+func (me *FileWriter) SetPadding(enabled bool, padByte byte) {
+	me.padFinal = enabled
+	me.padByte = padByte
+}
+
+// SetCloseWriter configures whether closing this FileWriter also closes the underlying writer.
+// If closeWriter is true (the default), the underlying writer is closed if it implements io.Closer.
+// If closeWriter is false, the underlying writer is left open so more data can be written to it.
+func (me *FileWriter) SetCloseWriter(closeWriter bool) {
+	me.closeWriter = closeWriter
+}
+
+// BytesConsumed returns the number of bytes of binary data accepted by this FileWriter so far, including any data still buffered.
+func (me *FileWriter) BytesConsumed() int64 {
+	return me.consumed
+}
+
+// BytesProduced returns the number of bytes of HEX records produced so far, including any records still in the output buffer.
+func (me *FileWriter) BytesProduced() int64 {
+	return me.produced
 }
 
 // Write writes the provided binary data in HEX format to the underlying writer.
-// If len(p) exceeds the recordSize of this FileWriter, multiple records will be written to the stream
-// Returns the number of bytes written (including the address and other header bytes of the record) or any errors encountered during writing.
+// If len(p) exceeds the recordSize of this FileWriter, multiple records will be written to the stream.
+// Data that does not fill a complete record is buffered until more data is written or the FileWriter is flushed or closed.
+// Returns the number of bytes of p that were accepted, which is len(p) unless an error occurs, and any errors encountered during writing.
 func (me *FileWriter) Write(p []byte) (n int, err error) {
 
 	if me.closed {
 		return 0, errors.New("This FileWriter is closed")
 	}
 
-	sum := 0
+	for n < len(p) {
 
-	for i := 0; i < len(p); i++ {
+		c := copy(me.buffer[me.bufferIndex:], p[n:])
+		me.bufferIndex += c
 
-		me.buffer[me.bufferIndex] = p[i]
-		me.bufferIndex++
+		if me.bufferIndex >= me.recordSize {
+			me.bufferIndex = 0
+			if _, err = me.writeDataRecord(me.buffer); err != nil {
+				return n, err
+			}
+		}
+
+		n += c
+		me.consumed += int64(c)
+	}
+	return n, nil
+}
+
+// ReadFrom reads binary data from r until EOF or an error occurs and writes it in HEX format to the underlying writer.
+// The data is read directly into the record buffer, so no intermediate copies are made.
+// This allows io.Copy to use the FileWriter efficiently.
+// Returns the number of bytes read from r and any errors encountered during reading or writing, other than io.EOF.
+func (me *FileWriter) ReadFrom(r io.Reader) (n int64, err error) {
+
+	if me.closed {
+		return 0, errors.New("This FileWriter is closed")
+	}
+
+	for {
+		c, readErr := r.Read(me.buffer[me.bufferIndex:])
+		me.bufferIndex += c
+		n += int64(c)
+		me.consumed += int64(c)
 
 		if me.bufferIndex >= me.recordSize {
 			me.bufferIndex = 0
-			n, err = me.writeDataRecord(me.buffer)
-			sum += n
-			if err != nil {
-				return sum, err
+			if _, err = me.writeDataRecord(me.buffer); err != nil {
+				return n, err
 			}
 		}
+
+		if readErr == io.EOF {
+			return n, nil
+		} else if readErr != nil {
+			return n, readErr
+		}
 	}
-	return sum, err
+}
+
+// Flush writes any buffered data as a (possibly short) record and writes all buffered records to the underlying writer.
+// Data written after a flush starts a new record at the next address.
+// Returns any errors encountered during writing.
+func (me *FileWriter) Flush() error {
+
+	if me.closed {
+		return errors.New("This FileWriter is closed")
+	}
+
+	if me.bufferIndex > 0 {
+		data := me.buffer[:me.bufferIndex]
+		me.bufferIndex = 0
+
+		if _, err := me.writeDataRecord(data); err != nil {
+			return err
+		}
+	}
+
+	return me.records.Flush()
+}
+
+// SetAddress flushes any buffered data and moves the address of the next byte written to the specified absolute address.
+// This allows writing data that is not contiguous, such as several separate blocks of memory.
+// The address is always a byte address, even when this FileWriter writes word addresses.
+// Returns an error if the address is outside the address space of this FileWriter's file type, is not aligned to its address unit, or if flushing fails.
+func (me *FileWriter) SetAddress(address uint32) error {
+
+	if uint64(address) >= me.addressSpace() {
+		return fmt.Errorf("Address %08X is outside the address space of I%dHEX files", address, int(me.fileType))
+	}
+
+	if uint64(address)%me.unit != 0 {
+		return fmt.Errorf("Address %08X is not aligned to the address unit of %d bytes", address, me.unit)
+	}
+
+	if err := me.Flush(); err != nil {
+		return err
+	}
+
+	me.address = uint64(address)
+	return nil
 }
 
 // Close closes this writer and flushes any remaining buffered data to the underling writer.
-// This also closes the underlying writer if possible and writes the final EOF record to the writer.
+// The remaining data is written as a short record or padded to the full record size, depending on SetPadding.
+// This also writes the final EOF record to the writer (unless the EOF option is EOFOmit) and closes the underlying writer if possible, depending on SetCloseWriter.
 // Returns any errors encountered during closing or when closing the underlying writer.
 func (me *FileWriter) Close() error {
 
@@ -65,26 +191,43 @@ func (me *FileWriter) Close() error {
 	me.closed = true
 
 	if me.bufferIndex > 0 {
-		for i := me.bufferIndex + 1; i < len(me.buffer); i++ {
-			me.buffer[i] = 0
+		data := me.buffer[:me.bufferIndex]
+
+		if me.padFinal {
+			for i := me.bufferIndex; i < len(me.buffer); i++ {
+				me.buffer[i] = me.padByte
+			}
+			data = me.buffer
 		}
 
-		if _, err := me.writeDataRecord(me.buffer); err != nil {
+		if _, err := me.writeDataRecord(data); err != nil {
 			return err
 		}
 	}
 
-	endRecord := Record{
-		Type:          RecordEOF,
-		Data:          make([]byte, 0),
-		AddressOffset: 0,
+	if me.opts.EOF != EOFOmit {
+		endRecord := Record{
+			Type:          RecordEOF,
+			Data:          make([]byte, 0),
+			AddressOffset: 0,
+		}
+
+		if _, err := me.writeRecord(endRecord); err != nil {
+			return err
+		}
 	}
 
-	if _, err := endRecord.write(me.writer); err != nil {
+	if err := me.records.Flush(); err != nil {
 		return err
 	}
 
-	if c, ok := me.writer.(io.Closer); ok {
+	if me.compressor != nil {
+		if err := me.compressor.Close(); err != nil {
+			return err
+		}
+	}
+
+	if c, ok := me.writer.(io.Closer); ok && me.closeWriter {
 		return c.Close()
 	}
 	return nil
@@ -97,86 +240,184 @@ func NewFileWriter(w io.Writer, recordSize int) (*FileWriter, error) {
 	return NewFileWriterType(w, recordSize, I32HEX)
 }
 
-// NewFileWriterType create and initialize a new FileWriter with the specified underlying writer to write HEX data into.
-// All records written by this FileWriter will have data size of recordSize bytes.
-// Returns a newly created and initialized FileWriter or an error if recordSize exceeds the maximum HEX data length (255 bytes)
+// NewFileWriterType is equivalent to calling NewFileWriterOptions(w, recordSize, fileType, WriteOptions{})
 func NewFileWriterType(w io.Writer, recordSize int, fileType FileType) (*FileWriter, error) {
 
+	return NewFileWriterOptions(w, recordSize, fileType, WriteOptions{})
+}
+
+// NewFileWriterOptions create and initialize a new FileWriter with the specified underlying writer to write HEX data into.
+// All records written by this FileWriter will have data size of recordSize bytes.
+// Records are formatted according to opts. Any EOF mode other than EOFOmit writes a single EOF record on Close. OmitStartRecords has no effect since a FileWriter never writes start records.
+// If opts.AddressUnit is greater than 1, record addresses are written as word addresses and recordSize must be a multiple of the address unit.
+// If opts.Gzip is set, the records are gzip compressed, and the compressed stream is completed when the FileWriter is closed.
+// Returns a newly created and initialized FileWriter or an error if recordSize exceeds the maximum HEX data length (255 bytes) or the address unit is invalid
+func NewFileWriterOptions(w io.Writer, recordSize int, fileType FileType, opts WriteOptions) (*FileWriter, error) {
+
+	var compressor *gzip.Writer
+	records := io.Writer(w)
+
+	if opts.Gzip {
+		compressor = gzip.NewWriter(w)
+		records = compressor
+	}
+
+	return newFileWriter(w, compressor, NewRecordWriterOptions(records, opts), recordSize, fileType, opts)
+}
+
+// newFileWriter creates a FileWriter that sends its records to the provided sink.
+// w and compressor are the underlying writer and gzip stream closed along with the FileWriter, and may be nil when the sink does not write to a stream.
+// Returns an error if the record size or address unit of opts is invalid.
+func newFileWriter(w io.Writer, compressor *gzip.Writer, records recordSink, recordSize int, fileType FileType, opts WriteOptions) (*FileWriter, error) {
+
 	if recordSize > recordMaximumDataSize || recordSize <= 0 {
 		return nil, fmt.Errorf("HEX record size cannot exceed %d bytes and must be greater than 0 bytes. Requested record size: %d bytes", recordMaximumDataSize, recordSize)
 	}
 
+	unit, err := addressUnitSize(opts.AddressUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	if recordSize%unit != 0 {
+		return nil, fmt.Errorf("HEX record size must be a multiple of the address unit of %d bytes. Requested record size: %d bytes", unit, recordSize)
+	}
+
 	return &FileWriter{
 		recordSize:  recordSize,
-		recordCount: 0,
+		address:     0,
+		base:        0,
 		buffer:      make([]byte, recordSize),
 		bufferIndex: 0,
 		fileType:    fileType,
 		writer:      w,
+		records:     records,
+		compressor:  compressor,
 		closed:      false,
+		padFinal:    false,
+		padByte:     0xFF,
+		closeWriter: true,
+		consumed:    0,
+		produced:    0,
+		written:     0,
+		unit:        uint64(unit),
+		opts:        opts,
 	}, nil
 }
 
-// writeDataRecord handles writing a single record to the underlying writer.
-// Automatically increments the FileWriter record count as new records are written.
-// Automatically inserts address extension records as needed when record counts exceed I8HEX specifications.
+// writeDataRecord handles writing a single record's worth of data to the underlying writer.
+// Automatically advances the FileWriter address as data is written.
+// Automatically inserts address extension records as needed whenever the address crosses into a new 64 KiB segment.
+// The data is split into two records if it would otherwise cross a 64 KiB boundary.
 // Returns the number of bytes written to the underlying writer and any errors that occurred during writing.
+// Returns an error without writing anything if the data does not fit in the address space of the FileWriter's file type.
 func (me *FileWriter) writeDataRecord(data []byte) (int, error) {
 
-	if (me.fileType == I8HEX && me.recordCount >= writerMaximumI8HEXRecords) || (me.fileType == I16HEX && me.recordCount >= writerMaximumI16HEXRecords) || (me.fileType == I32HEX && me.recordCount >= writerMaximumI32HEXRecords) {
-		return 0, fmt.Errorf("Maximum file record count for I%dHEX file exceeded", int(me.fileType))
+	if me.address+uint64(len(data)) > me.addressSpace() {
+		return 0, fmt.Errorf("Maximum address space of %d bytes for I%dHEX file exceeded", me.addressSpace(), int(me.fileType))
 	}
 
-	address := uint16(me.recordCount & 0x00000000000000FF)
-	sum := 0
-	addressExtension := uint16(0)
-
-	// evaluates for I32HEX and I16HEX. Whenever the maximum 16 bit address is reached
-	// calculates the appropriate data value for the extended address record about to be written
-	if address == 0 && me.recordCount > 0 {
-		// Linear Segment Adddress (future data records' addresses get an additional upper 16 bits equal to this value to create a 32 bit address)
-		if me.fileType == I32HEX {
-			addressExtension = uint16((me.recordCount & 0x000000000000FF00) >> 16)
-			// Extended Segment Address (future data records' addresses get offset by this value x 16)
-		} else if me.fileType == I16HEX {
-			addressExtension = uint16(me.recordCount / 16)
-		}
+	// only the final record may end in a partial word, since the data after it would start part way through a word
+	if me.address%me.unit != 0 {
+		return 0, fmt.Errorf("Address %08X is not aligned to the address unit of %d bytes", me.address, me.unit)
 	}
 
-	// if an address extension was needed, this block of code generates the address extension record and writes it to the writer
-	if addressExtension > 0 {
+	segmentSize := writerSegmentSize * me.unit
+	sum := 0
+
+	for len(data) > 0 {
+
+		// if the address has moved into a new segment, an address extension record is needed before any more data records
+		if base := me.address &^ (segmentSize - 1); base != me.base {
+
+			n, err := me.writeExtensionRecord(base)
+			sum += n
+
+			if err != nil {
+				return sum, err
+			}
+		}
 
-		b := make([]byte, 0)
-		binary.BigEndian.PutUint16(b, addressExtension)
+		offset := me.address - me.base
+		size := uint64(len(data))
 
-		// Set the appropriate extension record type depending on HEX file type
-		t := RecordExtLinear
-		if me.fileType == I16HEX {
-			t = RecordExtSegment
+		if offset+size > segmentSize {
+			size = segmentSize - offset
 		}
 
 		r := Record{
-			Type:          t,
-			AddressOffset: 0,
-			Data:          b,
+			Type:          RecordData,
+			AddressOffset: uint16(offset / me.unit),
+			Data:          data[:size],
 		}
 
-		n, err := r.write(me.writer)
+		n, err := me.writeRecord(r)
 		sum += n
 
 		if err != nil {
 			return sum, err
 		}
+
+		me.address += size
+		data = data[size:]
 	}
 
-	me.recordCount++
+	return sum, nil
+}
+
+// writeExtensionRecord writes the address extension record that moves the base address of the following data records to base.
+// Linear Segment Adddress records are used for I32HEX (future data records' addresses get an additional upper 16 bits equal to base / 65536).
+// Extended Segment Address records are used for I16HEX (future data records' addresses get offset by the record value x 16).
+// Returns the number of bytes written to the underlying writer and any errors that occurred during writing.
+func (me *FileWriter) writeExtensionRecord(base uint64) (int, error) {
+
+	b := make([]byte, addressExtensionDataSize)
+
+	// Set the appropriate extension record type depending on HEX file type
+	t := RecordExtLinear
+	if me.fileType == I16HEX {
+		t = RecordExtSegment
+		binary.BigEndian.PutUint16(b, uint16(base/me.unit>>4))
+	} else {
+		binary.BigEndian.PutUint16(b, uint16(base/me.unit>>16))
+	}
 
 	r := Record{
-		Type:          RecordData,
-		AddressOffset: address,
-		Data:          data,
+		Type:          t,
+		AddressOffset: 0,
+		Data:          b,
 	}
 
-	n, err := r.write(me.writer)
-	return n + sum, err
+	n, err := me.writeRecord(r)
+	if err == nil {
+		me.base = base
+	}
+	return n, err
+}
+
+// writeRecord writes a single record to the buffered record writer and adds the number of bytes written to the produced byte count.
+// Reports the progress of the FileWriter if a progress callback was provided.
+// Returns the number of bytes written and any errors that occurred during writing.
+func (me *FileWriter) writeRecord(r Record) (int, error) {
+	n, err := me.records.WriteRecord(r)
+	me.produced += int64(n)
+
+	if err == nil {
+		me.written++
+		if me.opts.Progress != nil {
+			me.opts.Progress(me.written, me.produced)
+		}
+	}
+	return n, err
+}
+
+// addressSpace returns the number of bytes addressable by this FileWriter's file type and address unit
+func (me *FileWriter) addressSpace() uint64 {
+
+	if me.fileType == I8HEX {
+		return writerI8HEXAddressSpace * me.unit
+	} else if me.fileType == I16HEX {
+		return writerI16HEXAddressSpace * me.unit
+	}
+	return writerI32HEXAddressSpace * me.unit
 }
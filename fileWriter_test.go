@@ -0,0 +1,432 @@
+package ihex
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// writeAndRead writes data at address with a FileWriter and reads the output back with NewFile.
+// Fails the test if the file read back is not of the expected type, has a data record longer than recordSize or crossing a 64 KiB segment,
+// or does not hold exactly the data written.
+func writeAndRead(t *testing.T, fileType FileType, recordSize int, address uint32, data []byte) {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriterType(&buf, recordSize, fileType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.SetAddress(address); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFile(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a file without address extension records is read back as I8HEX
+	if f.GetType() != fileType && !(f.GetType() == I8HEX && uint64(address)+uint64(len(data)) <= 1<<16) {
+		t.Fatalf("read back file type %d, expected %d", f.GetType(), fileType)
+	}
+
+	for i, r := range f.Records() {
+		if r.Type != RecordData {
+			continue
+		}
+
+		if len(r.Data) > recordSize {
+			t.Fatalf("record %d holds %d bytes, more than the record size of %d", i, len(r.Data), recordSize)
+		}
+
+		if int(r.AddressOffset)+len(r.Data) > 1<<16 {
+			t.Fatalf("record %d at offset %04X with %d bytes crosses a 64 KiB segment", i, r.AddressOffset, len(r.Data))
+		}
+	}
+
+	img, err := NewImage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(img.Segments) != 1 || img.Segments[0].Address != address || !bytes.Equal(img.Segments[0].Data, data) {
+		t.Fatalf("read back %d segments, expected %d bytes at %08X", len(img.Segments), len(data), address)
+	}
+}
+
+// testData returns size bytes of data that does not repeat every 256 bytes
+func testData(size int) []byte {
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i>>8)
+	}
+	return data
+}
+
+func TestFileWriterRoundTrip(t *testing.T) {
+
+	for _, c := range []struct {
+		name       string
+		fileType   FileType
+		recordSize int
+		address    uint32
+		size       int
+	}{
+		{"RecordSize7", I32HEX, 7, 0, 1000},
+		{"I8HEX", I8HEX, 16, 0x100, 4000},
+		{"I16HEX", I16HEX, 16, 0x1FF00, 0x20000},
+		{"Large", I32HEX, 32, 0x08000000, 200 * 1024},
+		{"CrossSegment", I32HEX, 32, 0xFFF0, 64},
+		{"CrossSegmentI16HEX", I16HEX, 255, 0xFFFF, 300},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			writeAndRead(t, c.fileType, c.recordSize, c.address, testData(c.size))
+		})
+	}
+}
+
+func TestFileWriterI8HEXOverflow(t *testing.T) {
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriterType(&buf, 16, I8HEX)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(testData(1<<16 + 1)); err == nil {
+		err = fw.Close()
+		if err == nil {
+			t.Fatal("writing past the end of the I8HEX address space did not fail")
+		}
+	}
+}
+
+// countingWrites is a writer that counts the calls made to Write
+type countingWrites struct {
+	bytes.Buffer
+	calls int
+}
+
+func (me *countingWrites) Write(p []byte) (int, error) {
+	me.calls++
+	return me.Buffer.Write(p)
+}
+
+func TestFileWriterBuffersOutput(t *testing.T) {
+
+	w := &countingWrites{}
+	fw, err := NewFileWriterOptions(w, 16, I32HEX, WriteOptions{BufferSize: 1 << 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, err := fw.Write(make([]byte, 16)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w.calls != 0 {
+		t.Fatalf("records reached the underlying writer before Flush: %d writes", w.calls)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.calls != 1 {
+		t.Fatalf("expected a single write of the buffered records on Close, got %d", w.calls)
+	}
+}
+
+// writeAll writes data with a FileWriter of 16 byte records in a single call to Write.
+// Returns the HEX output.
+func writeAll(t *testing.T, data []byte) []byte {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFileWriterWriteContract(t *testing.T) {
+
+	data := testData(100)
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writes of any size are accepted whole, whether they fill part of a record, a record exactly, or several records
+	for _, size := range []int{1, 3, 12, 16, 0, 33, 35} {
+		n, err := fw.Write(data[:size])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != size {
+			t.Fatalf("Write of %d bytes returned %d", size, n)
+		}
+		data = data[size:]
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := writeAll(t, testData(100)); !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("wrote:\n%s\nexpected:\n%s", buf.Bytes(), want)
+	}
+
+	if _, err := fw.Write([]byte{1}); err == nil {
+		t.Fatal("writing to a closed FileWriter did not fail")
+	}
+}
+
+func TestFileWriterReadFrom(t *testing.T) {
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// io.Copy uses ReadFrom, since the source is wrapped so that it does not implement io.WriterTo
+	n, err := io.Copy(fw, struct{ io.Reader }{bytes.NewReader(testData(1000))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1000 {
+		t.Fatalf("copied %d bytes, expected 1000", n)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := writeAll(t, testData(1000)); !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("wrote:\n%s\nexpected:\n%s", buf.Bytes(), want)
+	}
+
+	if _, err := fw.ReadFrom(bytes.NewReader([]byte{1})); err == nil {
+		t.Fatal("reading into a closed FileWriter did not fail")
+	}
+}
+
+func TestFileWriterFlush(t *testing.T) {
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("data reached the underlying writer before Flush: %q", buf.String())
+	}
+
+	if err := fw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := ":03000000010203F7\n"; buf.String() != want {
+		t.Fatalf("Flush wrote %q, expected %q", buf.String(), want)
+	}
+
+	// data written after a flush starts a new record at the next address
+	if _, err := fw.Write([]byte{4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := ":03000000010203F7\n:0100030004F8\n:00000001FF\n"; buf.String() != want {
+		t.Fatalf("wrote %q, expected %q", buf.String(), want)
+	}
+
+	if err := fw.Flush(); err == nil {
+		t.Fatal("flushing a closed FileWriter did not fail")
+	}
+}
+
+func TestFileWriterCloseWriter(t *testing.T) {
+
+	for _, closeWriter := range []bool{true, false} {
+		w := &closeRecorder{}
+
+		fw, err := NewFileWriter(w, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.SetCloseWriter(closeWriter)
+
+		if _, err := fw.Write([]byte{1, 2, 3}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if closed := w.closed != nil; closed != closeWriter {
+			t.Fatalf("SetCloseWriter(%t): underlying writer closed: %t", closeWriter, closed)
+		}
+
+		// every record reaches the underlying writer on Close, even when it is left open
+		if want := ":03000000010203F7\n:00000001FF\n"; w.String() != want {
+			t.Fatalf("SetCloseWriter(%t): wrote %q, expected %q", closeWriter, w.String(), want)
+		}
+
+		// closing again does nothing
+		if err := fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileWriterCounters(t *testing.T) {
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(testData(40)); err != nil {
+		t.Fatal(err)
+	}
+
+	// two full records of 44 characters each have been produced, but are still in the output buffer, and 8 bytes wait for the next record
+	if fw.BytesConsumed() != 40 || fw.BytesProduced() != 88 || buf.Len() != 0 {
+		t.Fatalf("consumed %d and produced %d bytes with %d written, expected 40, 88 and 0", fw.BytesConsumed(), fw.BytesProduced(), buf.Len())
+	}
+
+	if _, err := fw.ReadFrom(bytes.NewReader(testData(2))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the final record of 10 bytes is 32 characters long, and the EOF record is 12
+	if fw.BytesConsumed() != 42 || fw.BytesProduced() != 88+32+12 || int64(buf.Len()) != fw.BytesProduced() {
+		t.Fatalf("consumed %d and produced %d bytes with %d written, expected 42, 132 and 132", fw.BytesConsumed(), fw.BytesProduced(), buf.Len())
+	}
+}
+
+func TestFileWriterPadding(t *testing.T) {
+
+	for _, c := range []struct {
+		name    string
+		padding bool
+		padByte byte
+		want    []byte
+	}{
+		{"Short", false, 0, testData(20)[16:]},
+		{"Padded", true, 0xAA, append(testData(20)[16:], bytes.Repeat([]byte{0xAA}, 12)...)},
+		{"PaddedZero", true, 0x00, append(testData(20)[16:], make([]byte, 12)...)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			fw, err := NewFileWriter(&buf, 16)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.padding {
+				fw.SetPadding(true, c.padByte)
+			}
+
+			if _, err := fw.Write(testData(20)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := NewFile(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkRecords(t, f, []Record{
+				{Type: RecordData, AddressOffset: 0x0000, Data: testData(16)},
+				{Type: RecordData, AddressOffset: 0x0010, Data: c.want},
+				{Type: RecordEOF, Data: []byte{}},
+			})
+		})
+	}
+}
+
+func TestFileWriterPaddingFlush(t *testing.T) {
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.SetPadding(true, 0xAA)
+
+	// only the final record written by Close is padded, since padding a flushed record would overwrite the data after it
+	if _, err := fw.Write([]byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write([]byte{3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFile(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRecords(t, f, []Record{
+		{Type: RecordData, AddressOffset: 0x0000, Data: []byte{1, 2}},
+		{Type: RecordData, AddressOffset: 0x0002, Data: append([]byte{3}, bytes.Repeat([]byte{0xAA}, 15)...)},
+		{Type: RecordEOF, Data: []byte{}},
+	})
+}
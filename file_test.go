@@ -0,0 +1,342 @@
+package ihex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReferenceFilesRoundTrip reads HEX files written by GNU objcopy (see testdata/README) and checks that writing them back produces the same bytes
+func TestReferenceFilesRoundTrip(t *testing.T) {
+
+	for _, c := range []struct {
+		name     string
+		fileType FileType
+		opts     WriteOptions
+	}{
+		{"objcopy_i32.hex", I32HEX, WriteOptions{LineEnding: "\r\n"}},
+		{"objcopy_i16.hex", I16HEX, WriteOptions{LineEnding: "\r\n"}},
+		{"objcopy_i8.hex", I8HEX, WriteOptions{LineEnding: "\r\n"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", c.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := NewFile(bytes.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if f.GetType() != c.fileType {
+				t.Fatalf("detected file type %d, expected %d", f.GetType(), c.fileType)
+			}
+
+			var out bytes.Buffer
+			if err := WriteFileWithOptions(context.Background(), f, &out, c.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(out.Bytes(), src) {
+				t.Fatalf("written file does not match the original:\n%s\nexpected:\n%s", out.Bytes(), src)
+			}
+		})
+	}
+}
+
+// TestNewFileErrorPositions checks that errors found after a record is parsed still point at the offending line, column and text
+func TestNewFileErrorPositions(t *testing.T) {
+
+	for _, c := range []struct {
+		name   string
+		src    string
+		opts   ParseOptions
+		kind   error
+		line   int
+		column int
+	}{
+		{"RecordType", ":020000021000EC\n:020000040800F2\n", ParseOptions{}, ErrInvalidRecordType, 2, 8},
+		{"AddressRecordLength", ":0400000001020304F2\n:0100000408F3\n", ParseOptions{}, ErrStructure, 2, 10},
+		{"AddressRecordLengthWithSpan", ":0100000408F3\n", ParseOptions{MaxAddressSpan: 1 << 20}, ErrStructure, 1, 10},
+		{"DataAfterEOF", ":00000001FF\n:0400000001020304F2\n", ParseOptions{RejectDataAfterEOF: true}, ErrStructure, 2, 1},
+		{"DataAfterEOFConcurrent", ":00000001FF\n:0400000001020304F2\n", ParseOptions{RejectDataAfterEOF: true, Concurrency: 2}, ErrStructure, 2, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewFileWithOptions(context.Background(), strings.NewReader(c.src), c.opts)
+
+			var e *InvalidRecordError
+			if !errors.As(err, &e) {
+				t.Fatalf("expected an InvalidRecordError, got %v", err)
+			}
+
+			if !errors.Is(err, c.kind) || e.Line != c.line || e.Column != c.column {
+				t.Fatalf("error %q has kind %v at line %d, column %d. Expected %v at line %d, column %d", err, e.Kind, e.Line, e.Column, c.kind, c.line, c.column)
+			}
+
+			if want := strings.Split(c.src, "\n")[c.line-1]; e.Text != want {
+				t.Fatalf("error text %q, expected %q", e.Text, want)
+			}
+
+			if !strings.HasSuffix(e.Diagnostic(), "^") {
+				t.Fatalf("diagnostic does not point at the problem:\n%s", e.Diagnostic())
+			}
+		})
+	}
+}
+
+// TestConcurrentLimitsStopReading checks that the concurrent parser stops reading its input once the record or data limits are crossed
+func TestConcurrentLimitsStopReading(t *testing.T) {
+
+	src := benchmarkHex(t, 1<<20)
+
+	for _, opts := range []ParseOptions{
+		{Concurrency: 2, ChunkSize: 1000, MaxRecords: 100},
+		{Concurrency: 2, ChunkSize: 1000, MaxDataBytes: 3200},
+	} {
+		r := &countingReader{reader: bytes.NewReader(src)}
+
+		_, err := NewFileWithOptions(context.Background(), r, opts)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("expected a limit to be exceeded, got %v", err)
+		}
+
+		// 100 records take about 7.5 KB, and the line scanner reads ahead by a little more than that at most.
+		// Without stopping at the limit, the first whole chunk of 1000 lines would be read.
+		if r.count > 16<<10 {
+			t.Fatalf("read %d of %d bytes after a limit was exceeded", r.count, len(src))
+		}
+	}
+}
+
+// TestNewFileLongLine checks that a line too long for the line scanner is reported like any other record that is too long
+func TestNewFileLongLine(t *testing.T) {
+
+	src := ":00000001FF\n:" + strings.Repeat("00", 40000) + "\n:00000001FF\n"
+
+	for _, opts := range []ParseOptions{{}, {Concurrency: 2}} {
+		_, err := NewFileWithOptions(context.Background(), strings.NewReader(src), opts)
+
+		var e *InvalidRecordError
+		if !errors.As(err, &e) || !errors.Is(err, ErrRecordTooLong) || e.Line != 2 {
+			t.Fatalf("concurrency %d: expected a record too long at line 2, got %v", opts.Concurrency, err)
+		}
+	}
+}
+
+// progressCall is the arguments of one call to a ProgressFunc
+type progressCall struct {
+	records int
+	bytes   int64
+}
+
+// progressSource returns a HEX file of records data records of 16 bytes, followed by an EOF record
+func progressSource(t *testing.T, records int) []byte {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	fw, err := NewFileWriter(&buf, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(testData(records * 16)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewFileProgress(t *testing.T) {
+
+	src := progressSource(t, 99)
+
+	for _, c := range []struct {
+		name  string
+		opts  ParseOptions
+		calls int
+	}{
+		{"Sequential", ParseOptions{}, 100},
+		{"Concurrent", ParseOptions{Concurrency: 4, ChunkSize: 10}, 10},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			calls := make([]progressCall, 0)
+			c.opts.Progress = func(records int, bytes int64) {
+				calls = append(calls, progressCall{records, bytes})
+			}
+
+			if _, err := NewFileWithOptions(context.Background(), bytes.NewReader(src), c.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(calls) != c.calls {
+				t.Fatalf("progress was reported %d times, expected %d", len(calls), c.calls)
+			}
+
+			// records count up by one record, or one chunk, at a time, and the bytes read never go backwards
+			step := 100 / c.calls
+			for i, call := range calls {
+				if call.records != (i+1)*step || i > 0 && call.bytes < calls[i-1].bytes {
+					t.Fatalf("progress call %d reported %d records and %d bytes after %+v", i, call.records, call.bytes, calls[:i])
+				}
+			}
+
+			if last := calls[len(calls)-1]; last.bytes != int64(len(src)) {
+				t.Fatalf("progress reported %d bytes read at the end, expected %d", last.bytes, len(src))
+			}
+		})
+	}
+}
+
+func TestNewFileCancel(t *testing.T) {
+
+	src := progressSource(t, 99)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewFileContext(ctx, bytes.NewReader(src)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		opts  ParseOptions
+		calls int
+	}{
+		{"Sequential", ParseOptions{}, 3},
+		{"Concurrent", ParseOptions{Concurrency: 2, ChunkSize: 10}, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// cancelling part way through stops reading before the next record, or chunk of records, is added
+			calls := 0
+			c.opts.Progress = func(records int, bytes int64) {
+				calls++
+				if calls == c.calls {
+					cancel()
+				}
+			}
+
+			if _, err := NewFileWithOptions(ctx, bytes.NewReader(src), c.opts); !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected %v, got %v", context.Canceled, err)
+			}
+
+			if calls != c.calls {
+				t.Fatalf("progress was reported %d times after cancelling at call %d", calls, c.calls)
+			}
+		})
+	}
+}
+
+func TestWriteFileProgress(t *testing.T) {
+
+	f, err := NewFile(bytes.NewReader(progressSource(t, 9)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every record is 44 bytes long apart from the 12 byte EOF record
+	calls := make([]progressCall, 0)
+	opts := WriteOptions{Progress: func(records int, bytes int64) {
+		calls = append(calls, progressCall{records, bytes})
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteFileWithOptions(context.Background(), f, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 10 {
+		t.Fatalf("progress was reported %d times, expected 10", len(calls))
+	}
+
+	for i, call := range calls {
+		want := progressCall{i + 1, int64(i+1) * 44}
+		if i == 9 {
+			want.bytes = 9*44 + 12
+		}
+
+		if call != want {
+			t.Fatalf("progress call %d reported %+v, expected %+v", i, call, want)
+		}
+	}
+
+	if int64(buf.Len()) != calls[9].bytes {
+		t.Fatalf("wrote %d bytes, but progress reported %d", buf.Len(), calls[9].bytes)
+	}
+
+	// a FileWriter reports the same counts for the same records
+	fwCalls := make([]progressCall, 0)
+	fw, err := NewFileWriterOptions(&bytes.Buffer{}, 16, I32HEX, WriteOptions{Progress: func(records int, bytes int64) {
+		fwCalls = append(fwCalls, progressCall{records, bytes})
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write(testData(9 * 16)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fwCalls) != len(calls) {
+		t.Fatalf("FileWriter reported progress %d times, expected %d", len(fwCalls), len(calls))
+	}
+
+	for i := range calls {
+		if fwCalls[i] != calls[i] {
+			t.Fatalf("FileWriter progress call %d reported %+v, expected %+v", i, fwCalls[i], calls[i])
+		}
+	}
+}
+
+func TestWriteFileCancel(t *testing.T) {
+
+	f, err := NewFile(bytes.NewReader(progressSource(t, 9)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	if err := WriteFileContext(ctx, f, &buf); !errors.Is(err, context.Canceled) || buf.Len() != 0 {
+		t.Fatalf("expected %v with nothing written, got %v with %d bytes written", context.Canceled, err, buf.Len())
+	}
+
+	// cancelling part way through stops writing before the next record
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	opts := WriteOptions{BufferSize: 1, Progress: func(records int, bytes int64) {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+	}}
+
+	buf.Reset()
+	if err := WriteFileWithOptions(ctx, f, &buf, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	if calls != 3 || buf.Len() > 3*44 {
+		t.Fatalf("%d records and %d bytes were written after cancelling at record 3", calls, buf.Len())
+	}
+}
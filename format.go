@@ -0,0 +1,311 @@
+package ihex
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"sync"
+)
+
+// formatDetectSize is the number of bytes at the start of an input that Open passes to Format.Detect
+const formatDetectSize = 512
+
+// formatLineSkip defines which lines at the start of an input a format's reader skips, so that detection skips the same lines
+type formatLineSkip byte
+
+const (
+	// formatSkipNone skips no lines: the first line must be a record
+	formatSkipNone formatLineSkip = iota
+
+	// formatSkipEmpty skips empty lines
+	formatSkipEmpty
+
+	// formatSkipSpace skips lines that only contain whitespace, and ignores whitespace around the first line that does not
+	formatSkipSpace
+)
+
+// Format is a file format that memory images can be read from and written to.
+// Formats are registered with RegisterFormat so that Open can detect them from the contents of an input.
+type Format interface {
+	// Name returns the unique name of this format, such as "intelhex"
+	Name() string
+
+	// Detect returns true if head, the first bytes of an input (up to 512 bytes), looks like the start of a file in this format
+	Detect(head []byte) bool
+
+	// Decode reads a file in this format and creates an image of its contents
+	Decode(r io.Reader) (*Image, error)
+
+	// Encode writes the data of an image to w in this format
+	Encode(w io.Writer, img *Image) error
+}
+
+var (
+	// formatsLock guards formats
+	formatsLock sync.RWMutex
+
+	// formats are the registered formats, in the order Open tries them
+	formats = []Format{
+		&format{
+			name:   "intelhex",
+			detect: detectIntelHex,
+			decode: decodeIntelHex,
+			encode: encodeIntelHex,
+		},
+		&format{
+			name:   "dfuse",
+			detect: func(head []byte) bool { return bytes.HasPrefix(head, dfuseSignature) },
+			decode: decodeDfuSe,
+			encode: encodeDfuSe,
+		},
+		&format{
+			name: "uf2",
+			detect: func(head []byte) bool {
+				return len(head) >= 8 && binary.LittleEndian.Uint32(head[0:]) == uf2MagicStart0 && binary.LittleEndian.Uint32(head[4:]) == uf2MagicStart1
+			},
+			decode: func(r io.Reader) (*Image, error) { return ReadUF2(r, UF2Options{}) },
+			encode: func(w io.Writer, img *Image) error { return WriteUF2(w, img, UF2Options{}) },
+		},
+		&format{
+			name:   "xtek",
+			detect: detectExtendedTektronix,
+			decode: ReadExtendedTektronix,
+			encode: WriteExtendedTektronix,
+		},
+		&format{
+			name:   "tektronix",
+			detect: func(head []byte) bool { return detectHexLine(head, tekStartChar, formatSkipEmpty) },
+			decode: ReadTektronix,
+			encode: WriteTektronix,
+		},
+		&format{
+			name:   "mos",
+			detect: func(head []byte) bool { return detectHexLine(head, mosStartChar, formatSkipEmpty) },
+			decode: ReadMOS,
+			encode: WriteMOS,
+		},
+		&format{
+			name:   "titxt",
+			detect: func(head []byte) bool { return detectHexLine(head, titxtAddressChar, formatSkipSpace) },
+			decode: ReadTITXT,
+			encode: WriteTITXT,
+		},
+		&format{
+			name:   "coe",
+			detect: func(head []byte) bool { return bytes.Contains(bytes.ToLower(head), []byte(coeRadixKey)) },
+			decode: func(r io.Reader) (*Image, error) { return ReadCOE(r, MemoryOptions{}) },
+			encode: func(w io.Writer, img *Image) error { return WriteCOE(w, img, MemoryOptions{}) },
+		},
+		&format{
+			name: "mif",
+			detect: func(head []byte) bool {
+				upper := bytes.ToUpper(head)
+				return bytes.Contains(upper, []byte("WIDTH")) && bytes.Contains(upper, []byte("DEPTH"))
+			},
+			decode: func(r io.Reader) (*Image, error) { return ReadMIF(r, MemoryOptions{}) },
+			encode: func(w io.Writer, img *Image) error { return WriteMIF(w, img, MemoryOptions{}) },
+		},
+	}
+)
+
+// RegisterFormat adds a format to the formats detected by Open.
+// Formats are tried in the order they are registered, after the formats built into this package.
+// Registering a format with the same name as a registered format, ignoring case as LookupFormat does, replaces it, keeping its place in the order.
+func RegisterFormat(f Format) {
+
+	formatsLock.Lock()
+	defer formatsLock.Unlock()
+
+	for i, existing := range formats {
+		if strings.EqualFold(existing.Name(), f.Name()) {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Formats returns the registered formats, in the order Open tries them.
+// The built in formats are "intelhex", "dfuse", "uf2", "xtek", "tektronix", "mos", "titxt", "coe" and "mif".
+// Formats with word layout options (COE and MIF) use 8 bit words, and Verilog memory files are not registered since they cannot be told apart from TI-TXT files.
+func Formats() []Format {
+
+	formatsLock.RLock()
+	defer formatsLock.RUnlock()
+
+	return append(make([]Format, 0, len(formats)), formats...)
+}
+
+// LookupFormat returns the registered format with the specified name, ignoring case.
+// Returns false if no format with the name is registered.
+func LookupFormat(name string) (Format, bool) {
+
+	for _, f := range Formats() {
+		if strings.EqualFold(f.Name(), name) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Open detects the format of the provided reader from its first bytes and reads an image of its contents.
+// Input compressed with gzip or bzip2 is decompressed before its format is detected.
+// The registered formats are tried in order, and the first one whose Detect accepts the input decodes it.
+// Returns the new image and the format it was read in, ErrUnknownFormat if no registered format detects the input,
+// or any errors generated while decoding the input.
+func Open(r io.Reader) (*Image, Format, error) {
+
+	br := bufio.NewReaderSize(newDecompressReader(r), formatDetectSize)
+
+	head, err := br.Peek(formatDetectSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+
+	for _, f := range Formats() {
+		if f.Detect(head) {
+			img, err := f.Decode(br)
+			return img, f, err
+		}
+	}
+	return nil, nil, ErrUnknownFormat
+}
+
+// format is a Format made of functions, used for the formats built into this package
+type format struct {
+	name   string
+	detect func(head []byte) bool
+	decode func(r io.Reader) (*Image, error)
+	encode func(w io.Writer, img *Image) error
+}
+
+// Name returns the name of this format
+func (me *format) Name() string {
+	return me.name
+}
+
+// Detect returns true if head looks like the start of a file in this format
+func (me *format) Detect(head []byte) bool {
+	return me.detect(head)
+}
+
+// Decode reads a file in this format and creates an image of its contents
+func (me *format) Decode(r io.Reader) (*Image, error) {
+	return me.decode(r)
+}
+
+// Encode writes the data of an image to w in this format
+func (me *format) Encode(w io.Writer, img *Image) error {
+	return me.encode(w, img)
+}
+
+// detectIntelHex returns true if the first line of head is an Intel HEX record.
+// NewFile rejects blank lines, so they are not skipped.
+func detectIntelHex(head []byte) bool {
+	return detectHexLine(head, recordStartChar, formatSkipNone)
+}
+
+// decodeIntelHex reads an Intel HEX file of any type and creates an image of its contents
+func decodeIntelHex(r io.Reader) (*Image, error) {
+
+	f, err := NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(f)
+}
+
+// encodeIntelHex writes the data of an image as an I32HEX file with records of 16 bytes
+func encodeIntelHex(w io.Writer, img *Image) error {
+
+	f, err := img.File(I32HEX, 16)
+	if err != nil {
+		return err
+	}
+	return WriteFile(f, w)
+}
+
+// decodeDfuSe reads a DfuSe file and creates a single image holding the data of all of its targets
+func decodeDfuSe(r io.Reader) (*Image, error) {
+
+	dfu, err := ReadDfuSe(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	for _, t := range dfu.Targets {
+		for _, seg := range t.Image.Segments {
+			img.Set(seg.Address, seg.Data)
+		}
+	}
+	return img, nil
+}
+
+// encodeDfuSe writes the data of an image as a DfuSe file with a single target for alternate setting 0, meant for any device
+func encodeDfuSe(w io.Writer, img *Image) error {
+
+	return WriteDfuSe(w, &DfuSe{
+		Targets: []DfuSeTarget{{Image: img}},
+		Device:  0xFFFF,
+		Product: 0xFFFF,
+		Vendor:  0xFFFF,
+	})
+}
+
+// detectExtendedTektronix returns true if the first line of head that is not empty starts like an Extended Tektronix Hex record:
+// a '%' followed by a hexadecimal length and a data, symbol or termination record type
+func detectExtendedTektronix(head []byte) bool {
+
+	line := bytes.TrimLeft(head, "\r\n")
+	if len(line) < 4 || line[0] != '%' {
+		return false
+	}
+
+	for _, c := range line[1:3] {
+		if recordHexValues[c] == recordInvalidHexDigit {
+			return false
+		}
+	}
+	return line[3] == '3' || line[3] == '6' || line[3] == '8'
+}
+
+// detectHexLine returns true if the first line of head that is not skipped is the start character followed only by hexadecimal digits.
+// The line may be cut short by the end of head.
+func detectHexLine(head []byte, start byte, skip formatLineSkip) bool {
+
+	line := head
+	for {
+		end := bytes.IndexByte(line, '\n')
+		if end < 0 {
+			end = len(line)
+		}
+
+		text := bytes.TrimSuffix(line[:end], []byte{'\r'})
+		if skip == formatSkipSpace {
+			text = bytes.TrimSpace(text)
+		}
+
+		if skip == formatSkipNone || len(text) > 0 || end == len(line) {
+			line = text
+			break
+		}
+		line = line[end+1:]
+	}
+
+	if len(line) < 2 || line[0] != start {
+		return false
+	}
+
+	for _, c := range line[1:] {
+		if recordHexValues[c] == recordInvalidHexDigit {
+			return false
+		}
+	}
+	return true
+}
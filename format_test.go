@@ -0,0 +1,134 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOpenDetectsBuiltInFormats(t *testing.T) {
+
+	for _, f := range Formats() {
+		t.Run(f.Name(), func(t *testing.T) {
+			want := testImage()
+
+			// formats without a start address or with word layouts lose some of the image, so only the data at address 0 is compared
+			if f.Name() == "coe" || f.Name() == "mif" || f.Name() == "uf2" || f.Name() == "dfuse" || f.Name() == "titxt" || f.Name() == "mos" {
+				want = &Image{Segments: []Segment{{Address: 0, Data: testData(256)}}}
+			}
+
+			var buf bytes.Buffer
+			if err := f.Encode(&buf, want); err != nil {
+				t.Fatal(err)
+			}
+
+			img, format, err := Open(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if format.Name() != f.Name() {
+				t.Fatalf("detected %q", format.Name())
+			}
+
+			if !bytes.Equal(img.Segments[0].Data, want.Segments[0].Data) || img.Segments[0].Address != want.Segments[0].Address {
+				t.Fatalf("data read back at %08X does not match the data written", img.Segments[0].Address)
+			}
+		})
+	}
+}
+
+func TestOpenDetectionMatchesDecoding(t *testing.T) {
+
+	for _, c := range []struct {
+		src    string
+		format string
+	}{
+		{":0100000055AA\n:00000001FF\n", "intelhex"},
+		{"\n:0100000055AA\n:00000001FF\n", ""},
+		{" :0100000055AA\n:00000001FF\n", ""},
+		{"\n\n/00000000\n", "tektronix"},
+		{" /00000000\n", ""},
+		{"\r\n%0E81E800000000\n", "xtek"},
+		{"  \n  @0000\n 55\nq\n", "titxt"},
+		{"garbage\n", ""},
+	} {
+		_, format, err := Open(strings.NewReader(c.src))
+
+		if c.format == "" {
+			if !errors.Is(err, ErrUnknownFormat) {
+				t.Errorf("%q: expected ErrUnknownFormat, got %v", c.src, err)
+			}
+		} else if err != nil || format.Name() != c.format {
+			t.Errorf("%q: expected %s, got %v", c.src, c.format, err)
+		}
+	}
+}
+
+// testFormat is a Format that detects inputs starting with a prefix, used to test the format registry
+type testFormat struct {
+	name   string
+	prefix string
+}
+
+func (me *testFormat) Name() string {
+	return me.name
+}
+
+func (me *testFormat) Detect(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(me.prefix))
+}
+
+func (me *testFormat) Decode(r io.Reader) (*Image, error) {
+	return &Image{Segments: make([]Segment, 0)}, nil
+}
+
+func (me *testFormat) Encode(w io.Writer, img *Image) error {
+	_, err := io.WriteString(w, me.prefix)
+	return err
+}
+
+func TestRegisterFormat(t *testing.T) {
+
+	saved := Formats()
+	defer func() {
+		formatsLock.Lock()
+		formats = saved
+		formatsLock.Unlock()
+	}()
+
+	RegisterFormat(&testFormat{name: "test", prefix: "TEST"})
+	RegisterFormat(&testFormat{name: "later", prefix: "TEST"})
+
+	all := Formats()
+	if len(all) != len(saved)+2 || all[len(saved)].Name() != "test" || all[len(saved)+1].Name() != "later" {
+		t.Fatalf("registered formats are not added after the built in formats in order")
+	}
+
+	// the first registered format that detects an input decodes it
+	if _, f, err := Open(strings.NewReader("TEST")); err != nil || f.Name() != "test" {
+		t.Fatalf("expected the first registered format to be chosen, got %v", err)
+	}
+
+	// replacing a format keeps its place in the order
+	RegisterFormat(&testFormat{name: "TEST", prefix: "REPLACED"})
+
+	all = Formats()
+	if len(all) != len(saved)+2 || all[len(saved)].(*testFormat).prefix != "REPLACED" {
+		t.Fatal("replaced format did not keep its place in the order")
+	}
+
+	if _, f, err := Open(strings.NewReader("TEST")); err != nil || f.Name() != "later" {
+		t.Fatalf("expected the later format to detect the input once the first was replaced, got %v", err)
+	}
+
+	if f, ok := LookupFormat("Later"); !ok || f.Name() != "later" {
+		t.Fatal("LookupFormat does not ignore case")
+	}
+
+	if _, ok := LookupFormat("missing"); ok {
+		t.Fatal("LookupFormat found a format that is not registered")
+	}
+}
@@ -0,0 +1,81 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// FuzzParseRecord checks that parseRecord never panics, that every error it returns is an InvalidRecordError pointing into or just past the line,
+// and that every record it accepts encodes back to the same line
+func FuzzParseRecord(f *testing.F) {
+
+	f.Add([]byte(":10010000214601360121470136007EFE09D2190140"))
+	f.Add([]byte(":00000001FF"))
+
+	f.Fuzz(func(t *testing.T, line []byte) {
+		r, _, err := parseRecord(line, nil)
+
+		if err != nil {
+			var e *InvalidRecordError
+			if !errors.As(err, &e) {
+				t.Fatalf("error is not an InvalidRecordError: %v", err)
+			}
+
+			if e.Kind == nil || e.Column < 1 || e.Column > len(line)+2 {
+				t.Fatalf("error has kind %v and column %d for a line of %d characters", e.Kind, e.Column, len(line))
+			}
+			return
+		}
+
+		if out := r.appendTo(nil, recordHexDigits, ""); !bytes.EqualFold(out, line) {
+			t.Fatalf("record encoded as %q, parsed from %q", out, line)
+		}
+	})
+}
+
+// FuzzNewFile checks that NewFile never panics, that every error it returns for malformed input is typed, and that every file it accepts is read back with the same records after being written with WriteFile
+func FuzzNewFile(f *testing.F) {
+
+	f.Add([]byte(":020000040800F2\n:0400000001020304F2\n:00000001FF\n"))
+	f.Add([]byte(":020000021000EC\r\n:0100000055AA\r\n:00000001FF\r\n"))
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		file, err := NewFile(bytes.NewReader(src))
+		if err != nil {
+			// corrupt compressed input fails with the errors of the decompressor instead
+			compressed := bytes.HasPrefix(src, compressGzipMagic) || bytes.HasPrefix(src, compressBzip2Magic)
+
+			var e *InvalidRecordError
+			if !compressed && !errors.As(err, &e) && !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("error is not an InvalidRecordError: %v", err)
+			}
+			return
+		}
+
+		var out bytes.Buffer
+		if err := WriteFile(file, &out); err != nil {
+			t.Fatal(err)
+		}
+
+		again, err := NewFile(&out)
+		if err != nil {
+			t.Fatalf("written file cannot be read back: %v\n%s", err, out.Bytes())
+		}
+
+		if again.GetType() != file.GetType() {
+			t.Fatalf("written file read back as type %d, expected %d", again.GetType(), file.GetType())
+		}
+
+		a, b := file.Records(), again.Records()
+		if len(a) != len(b) {
+			t.Fatalf("written file read back with %d records, expected %d", len(b), len(a))
+		}
+
+		for i := range a {
+			if a[i].Type != b[i].Type || a[i].AddressOffset != b[i].AddressOffset || !bytes.Equal(a[i].Data, b[i].Data) {
+				t.Fatalf("record %d read back as %v, expected %v", i, b[i], a[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package ihex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Segment is a block of contiguous data starting at an absolute address
+type Segment struct {
+	Address uint32
+	Data    []byte
+}
+
+// End returns the address just past the last byte of this segment
+func (me Segment) End() uint64 {
+	return uint64(me.Address) + uint64(len(me.Data))
+}
+
+// Image is the memory contents described by a HEX file, independent of how they are split into records.
+// Data is held as segments at absolute addresses, so images can be converted between HEX file types and other file formats.
+// Segments are kept sorted by address. Segments never overlap or touch, since adjacent data is merged into a single segment.
+// The readers of the other file formats return an Image, which can be converted to an IHEX file with File.
+// If HasStart is true, Start holds the start address of the image: the EIP register for I32HEX files, or the CS:IP registers (CS in the upper 16 bits) for I16HEX files.
+type Image struct {
+	Segments []Segment
+	Start    uint32
+	HasStart bool
+}
+
+// Set writes data into this image starting at the specified absolute address.
+// Data that overlaps data already in the image replaces it.
+func (me *Image) Set(address uint32, data []byte) {
+
+	if len(data) == 0 {
+		return
+	}
+
+	end := uint64(address) + uint64(len(data))
+
+	// the common case of data written in order directly after the last segment
+	if n := len(me.Segments); n > 0 && me.Segments[n-1].End() == uint64(address) {
+		me.Segments[n-1].Data = append(me.Segments[n-1].Data, data...)
+		return
+	}
+
+	// find every segment that overlaps or touches the new data
+	first := sort.Search(len(me.Segments), func(i int) bool {
+		return me.Segments[i].End() >= uint64(address)
+	})
+	last := first
+	for last < len(me.Segments) && uint64(me.Segments[last].Address) <= end {
+		last++
+	}
+
+	merged := Segment{
+		Address: address,
+		Data:    append(make([]byte, 0, len(data)), data...),
+	}
+
+	if first < last {
+		low := me.Segments[first].Address
+		if low > address {
+			low = address
+		}
+
+		high := end
+		if e := me.Segments[last-1].End(); e > high {
+			high = e
+		}
+
+		merged = Segment{
+			Address: low,
+			Data:    make([]byte, high-uint64(low)),
+		}
+
+		for _, seg := range me.Segments[first:last] {
+			copy(merged.Data[seg.Address-low:], seg.Data)
+		}
+		copy(merged.Data[address-low:], data)
+	}
+
+	me.Segments = append(me.Segments[:first], append([]Segment{merged}, me.Segments[last:]...)...)
+}
+
+// blocks returns a copy of this image made of whole blocks of size bytes, at addresses that are multiples of size.
+// Every block that holds any data is filled with fill before the data is written over the top, and blocks are cut short at the end of the 32 bit address space.
+func (me *Image) blocks(size uint64, fill byte) *Image {
+
+	blocks := &Image{
+		Segments: make([]Segment, 0, len(me.Segments)),
+		Start:    me.Start,
+		HasStart: me.HasStart,
+	}
+
+	for _, seg := range me.Segments {
+		start := uint64(seg.Address) / size * size
+		end := (seg.End() + size - 1) / size * size
+		if end > 1<<32 {
+			end = 1 << 32
+		}
+
+		blocks.Set(uint32(start), bytes.Repeat([]byte{fill}, int(end-start)))
+	}
+
+	for _, seg := range me.Segments {
+		blocks.Set(seg.Address, seg.Data)
+	}
+	return blocks
+}
+
+// Size returns the total number of data bytes in this image
+func (me *Image) Size() int {
+
+	sum := 0
+	for _, seg := range me.Segments {
+		sum += len(seg.Data)
+	}
+	return sum
+}
+
+// File creates an IHEX file of the specified file type containing the data of this image.
+// Data records of recordSize bytes are written for each segment, along with any extended address records needed to reach its address.
+// The start address is written as a start record before the EOF record if the file type supports one.
+// Returns the new IHEX file or an error if the record size is invalid or the image does not fit in the address space of the file type.
+func (me *Image) File(fileType FileType, recordSize int) (File, error) {
+	return me.FileUnit(fileType, recordSize, 1)
+}
+
+// FileUnit creates a word-addressed IHEX file of the specified file type containing the data of this image.
+// Record addresses count words of unit bytes (1, 2 or 4) instead of bytes, so every segment must start on a word boundary and recordSize must be a multiple of unit.
+// The start address is written unchanged.
+// Returns the new IHEX file or an error if the record size or address unit is invalid or the image does not fit in the address space of the file type.
+func (me *Image) FileUnit(fileType FileType, recordSize int, unit int) (File, error) {
+
+	f, rf := newFileOfType(fileType)
+
+	fw, err := newFileWriter(nil, nil, recordFileSink{file: rf}, recordSize, f.GetType(), WriteOptions{AddressUnit: unit})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seg := range me.Segments {
+		if err := fw.SetAddress(seg.Address); err != nil {
+			return nil, err
+		}
+
+		if _, err := fw.Write(seg.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	if me.HasStart && f.GetType() != I8HEX {
+		data := make([]byte, addressStartDataSize)
+		binary.BigEndian.PutUint32(data, me.Start)
+
+		t := RecordStartLinear
+		if f.GetType() == I16HEX {
+			t = RecordStartSegment
+		}
+
+		if err := f.Insert(f.Len()-1, Record{Type: t, Data: data}); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// NewImage creates an image from the records of an IHEX file.
+// Extended address records are applied to find the absolute address of every data record.
+// Returns the new image or an IndexedRecordError if an address record of the file is malformed.
+func NewImage(f File) (*Image, error) {
+	return NewImageUnit(f, 1)
+}
+
+// NewImageUnit creates an image from the records of a word-addressed IHEX file, such as a Quartus memory initialization HEX file or a Microchip INHX16 file.
+// Record address offsets and extended address records count words of unit bytes (1, 2 or 4), so the absolute byte address of every data record is its word address multiplied by unit.
+// The start address is copied unchanged.
+// Returns the new image, an error if the address unit is invalid, or an IndexedRecordError if an address record of the file is malformed.
+func NewImageUnit(f File, unit int) (*Image, error) {
+
+	size, err := addressUnitSize(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	addresses := addressState{}
+
+	for i, r := range f.All() {
+		address, err := addresses.next(r)
+
+		if err != nil {
+			pos, _ := f.Position(i)
+			return nil, &IndexedRecordError{
+				Index:       i,
+				RecordError: setRecordErrorText(setRecordErrorLine(err, pos.Line), recordErrorText(r)),
+			}
+		}
+
+		if r.Type == RecordData {
+			byteAddress := uint64(address) * uint64(size)
+
+			if byteAddress+uint64(len(r.Data)) > 1<<32 {
+				return nil, &IndexedRecordError{
+					Index: i,
+					RecordError: &InvalidRecordError{
+						Message: fmt.Sprintf("Record data at address %08X extends past the end of the 32 bit address space", byteAddress),
+						Kind:    ErrStructure,
+					},
+				}
+			}
+			img.Set(uint32(byteAddress), r.Data)
+		} else if r.Type == RecordStartSegment || r.Type == RecordStartLinear {
+			img.Start = binary.BigEndian.Uint32(r.Data)
+			img.HasStart = true
+		}
+	}
+
+	return img, nil
+}
+
+// ByteAddressedFile converts a word-addressed IHEX file, whose record addresses count words of unit bytes (1, 2 or 4), to a byte-addressed I32HEX file with records of 16 bytes.
+// Returns the new I32HEX file, an error if the address unit is invalid, or an IndexedRecordError if an address record of the file is malformed.
+func ByteAddressedFile(f File, unit int) (*I32HEXFile, error) {
+	return newImageFile[i32hexType](NewImageUnit(f, unit))
+}
+
+// newImageFile converts an image into an IHEX file of the file type fixed by T, with records of 16 bytes.
+// It takes the results of a function that creates an image, and returns its error unchanged if it failed.
+func newImageFile[T fileTypeParam](img *Image, err error) (*typedFile[T], error) {
+
+	if err != nil {
+		return nil, err
+	}
+
+	var t T
+	f, err := img.File(t.fileType(), 16)
+	if err != nil {
+		return nil, err
+	}
+	return f.(*typedFile[T]), nil
+}
+
+// WordAddressedFile converts a byte-addressed IHEX file to a word-addressed I32HEX file, whose record addresses count words of unit bytes (1, 2 or 4), with records of 16 bytes.
+// Returns the new I32HEX file, an error if the address unit is invalid or the data of the file does not start on word boundaries,
+// or an IndexedRecordError if an address record of the file is malformed.
+func WordAddressedFile(f File, unit int) (*I32HEXFile, error) {
+
+	img, err := NewImage(f)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := img.FileUnit(I32HEX, 16, unit)
+	if err != nil {
+		return nil, err
+	}
+	return out.(*I32HEXFile), nil
+}
+
+// recordFileSink adds the records generated by a FileWriter to a RecordFile
+type recordFileSink struct {
+	file *RecordFile
+}
+
+// WriteRecord adds a copy of the record to the end of the file.
+// Returns 0 bytes written, since the record is not encoded, and any error adding the record.
+func (me recordFileSink) WriteRecord(r Record) (int, error) {
+	r.Data = append(make([]byte, 0, len(r.Data)), r.Data...)
+	return 0, me.file.Add(r)
+}
+
+// Flush does nothing, since records are added to the file as they are written
+func (me recordFileSink) Flush() error {
+	return nil
+}
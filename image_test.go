@@ -0,0 +1,53 @@
+package ihex
+
+import (
+	"testing"
+)
+
+func TestImageSet(t *testing.T) {
+
+	type set struct {
+		address uint32
+		data    []byte
+	}
+
+	for _, c := range []struct {
+		name string
+		sets []set
+		want []Segment
+	}{
+		{"Append", []set{{0x10, []byte{1, 2}}, {0x12, []byte{3}}}, []Segment{{0x10, []byte{1, 2, 3}}}},
+		{"Gap", []set{{0x10, []byte{1}}, {0x20, []byte{2}}}, []Segment{{0x10, []byte{1}}, {0x20, []byte{2}}}},
+		{"OutOfOrder", []set{{0x20, []byte{2}}, {0x10, []byte{1}}}, []Segment{{0x10, []byte{1}}, {0x20, []byte{2}}}},
+		{"TouchBefore", []set{{0x10, []byte{2}}, {0x0F, []byte{1}}}, []Segment{{0x0F, []byte{1, 2}}}},
+		{"Overwrite", []set{{0x10, []byte{1, 2, 3, 4}}, {0x11, []byte{9, 9}}}, []Segment{{0x10, []byte{1, 9, 9, 4}}}},
+		{"OverlapStart", []set{{0x10, []byte{1, 2}}, {0x0F, []byte{9, 9}}}, []Segment{{0x0F, []byte{9, 9, 2}}}},
+		{"OverlapEnd", []set{{0x10, []byte{1, 2}}, {0x11, []byte{9, 9}}}, []Segment{{0x10, []byte{1, 9, 9}}}},
+		{"Cover", []set{{0x10, []byte{1}}, {0x0F, []byte{9, 9, 9}}}, []Segment{{0x0F, []byte{9, 9, 9}}}},
+		{"Bridge", []set{{0x10, []byte{1}}, {0x14, []byte{2}}, {0x20, []byte{3}}, {0x11, []byte{9, 9, 9}}}, []Segment{{0x10, []byte{1, 9, 9, 9, 2}}, {0x20, []byte{3}}}},
+		{"BridgeMany", []set{{0x10, []byte{1}}, {0x12, []byte{2}}, {0x14, []byte{3}}, {0x11, []byte{9, 9, 9}}}, []Segment{{0x10, []byte{1, 9, 9, 9, 3}}}},
+		{"Empty", []set{{0x10, []byte{1}}, {0x08, nil}}, []Segment{{0x10, []byte{1}}}},
+		{"AddressSpaceEnd", []set{{0xFFFFFFFE, []byte{1, 2}}, {0xFFFFFFFF, []byte{9}}}, []Segment{{0xFFFFFFFE, []byte{1, 9}}}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			img := &Image{Segments: make([]Segment, 0)}
+			for _, s := range c.sets {
+				img.Set(s.address, s.data)
+			}
+			checkImage(t, img, &Image{Segments: c.want})
+		})
+	}
+}
+
+func TestImageSetCopiesData(t *testing.T) {
+
+	data := []byte{1, 2, 3}
+
+	img := &Image{Segments: make([]Segment, 0)}
+	img.Set(0x10, data)
+	data[0] = 9
+
+	if img.Segments[0].Data[0] != 1 {
+		t.Fatalf("image shares the storage of the data passed to Set")
+	}
+}
@@ -0,0 +1,86 @@
+package ihex
+
+import "io"
+
+// parseLimits enforces the resource limits and the EOF rule of a set of ParseOptions while records are read in file order
+type parseLimits struct {
+	opts      ParseOptions
+	eof       bool
+	records   int64
+	dataBytes int64
+	addresses addressState
+	low       uint64
+	high      uint64
+	hasData   bool
+}
+
+// add counts a record against the record, data and address span limits.
+// Returns a LimitExceededError as soon as any limit is crossed, or an error if the record's address can not be resolved.
+// Returns an InvalidRecordError if the options reject records after an EOF record and the record follows one.
+func (me *parseLimits) add(r Record) error {
+
+	if me.eof && me.opts.RejectDataAfterEOF {
+		return &InvalidRecordError{
+			Message: "Record found after the EOF record",
+			Kind:    ErrStructure,
+			Column:  1,
+		}
+	}
+	me.eof = me.eof || r.Type == RecordEOF
+
+	me.records++
+	if me.opts.MaxRecords > 0 && me.records > me.opts.MaxRecords {
+		return &LimitExceededError{
+			Limit: "MaxRecords",
+			Max:   me.opts.MaxRecords,
+		}
+	}
+
+	me.dataBytes += int64(len(r.Data))
+	if me.opts.MaxDataBytes > 0 && me.dataBytes > me.opts.MaxDataBytes {
+		return &LimitExceededError{
+			Limit: "MaxDataBytes",
+			Max:   me.opts.MaxDataBytes,
+		}
+	}
+
+	if me.opts.MaxAddressSpan <= 0 {
+		return nil
+	}
+
+	address, err := me.addresses.next(r)
+	if err != nil {
+		return err
+	}
+
+	if r.Type != RecordData || len(r.Data) == 0 {
+		return nil
+	}
+
+	low := uint64(address)
+	high := low + uint64(len(r.Data))
+
+	if !me.hasData || low < me.low {
+		me.low = low
+	}
+	if !me.hasData || high > me.high {
+		me.high = high
+	}
+	me.hasData = true
+
+	if me.high-me.low > uint64(me.opts.MaxAddressSpan) {
+		return &LimitExceededError{
+			Limit: "MaxAddressSpan",
+			Max:   me.opts.MaxAddressSpan,
+		}
+	}
+	return nil
+}
+
+// newCountingReader creates a countingReader for r that fails once more than the MaxInputBytes limit of opts has been read
+func newCountingReader(r io.Reader, opts ParseOptions) *countingReader {
+	return &countingReader{
+		reader: r,
+		limit:  opts.MaxInputBytes,
+	}
+}
@@ -0,0 +1,61 @@
+package ihex
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// lineScanner scans the lines of a HEX file and keeps track of the byte offset of each line in the source
+type lineScanner struct {
+	*bufio.Scanner
+	offset int64
+	next   int64
+}
+
+// split splits lines the same way as bufio.ScanLines, recording the offset of the start of each line it returns
+func (me *lineScanner) split(data []byte, atEOF bool) (int, []byte, error) {
+
+	advance, token, err := bufio.ScanLines(data, atEOF)
+
+	if token != nil {
+		me.offset = me.next
+	}
+	me.next += int64(advance)
+
+	return advance, token, err
+}
+
+// Offset returns the byte offset in the source of the start of the line most recently returned by Scan
+func (me *lineScanner) Offset() int64 {
+	return me.offset
+}
+
+// lineErr returns the error that stopped the scanner, or nil if it reached the end of its input.
+// A line too long to fit in the scanner's buffer is reported as an IndexedRecordError for the record at index, like any other record that is too long.
+func (me *lineScanner) lineErr(index int) error {
+
+	err := me.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return &IndexedRecordError{
+			Index:       index,
+			RecordError: newLineError(ErrRecordTooLong, index+1, recordMaximumSizeChars+1, nil, "Maximum record size is %d bytes. Record is longer than %d characters", (recordMaximumSizeChars-1)/2, bufio.MaxScanTokenSize),
+		}
+	}
+	return err
+}
+
+// newLineScanner creates and initializes a new lineScanner that reads lines from r
+// Returns the newly created lineScanner
+func newLineScanner(r io.Reader) *lineScanner {
+
+	s := &lineScanner{
+		Scanner: bufio.NewScanner(r),
+		offset:  0,
+		next:    0,
+	}
+
+	s.Buffer(make([]byte, 0, recordMaximumSizeChars+2), bufio.MaxScanTokenSize)
+	s.Split(s.split)
+	return s
+}
@@ -0,0 +1,323 @@
+package ihex
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// memoryDefaultMaxBytes is the size limit of the memory read from or written to a file when MemoryOptions does not specify one
+const memoryDefaultMaxBytes = 64 << 20
+
+// MemoryOptions defines the layout of the memory words in an FPGA memory initialization file, such as a Xilinx COE file or a Quartus MIF file
+type MemoryOptions struct {
+	// WordWidth is the width of each memory word in bits: 8, 16, 32 or 64. A value of 0 uses 8 bit words.
+	WordWidth int
+
+	// BigEndian stores the lowest addressed byte of each word in its most significant bits instead of its least significant bits
+	BigEndian bool
+
+	// Depth is the number of words in the memory. A value of 0 uses just enough words to hold the data of the image being written.
+	// The memory always starts at word address 0, so an image with data at a high address, such as 0x08000000, needs a memory at least that large.
+	Depth int
+
+	// MaxBytes is the maximum number of bytes of memory read from or written to a file.
+	// When writing, it limits the size of the whole memory, Depth words. When reading, it limits the total size of the words set by the file,
+	// which guards against small MIF files with address ranges that expand to gigabytes of data.
+	// A value of 0 uses a limit of 64 MiB. Negative values mean no limit.
+	MaxBytes int64
+
+	// Fill is the value written for words that have no data in the image.
+	// Bytes of partially filled words that have no data take the value of the same bytes of Fill.
+	Fill uint64
+
+	// Radix is the radix data words are written in: 2, 8 (MIF only), 10 or 16. A value of 0 writes hexadecimal words.
+	Radix int
+}
+
+// radix returns the radix data words are written in
+func (me MemoryOptions) radix() int {
+
+	if me.Radix == 0 {
+		return 16
+	}
+	return me.Radix
+}
+
+// maxBytes returns the maximum number of bytes of memory read from or written to a file
+func (me MemoryOptions) maxBytes() uint64 {
+
+	if me.MaxBytes == 0 {
+		return memoryDefaultMaxBytes
+	} else if me.MaxBytes < 0 {
+		return math.MaxInt
+	}
+	return uint64(me.MaxBytes)
+}
+
+// checkBytes returns a LimitExceededError if a memory of n bytes is larger than MaxBytes
+func (me MemoryOptions) checkBytes(n uint64) error {
+
+	if n > me.maxBytes() {
+		return &LimitExceededError{
+			Limit: "MaxBytes",
+			Max:   int64(me.maxBytes()),
+		}
+	}
+	return nil
+}
+
+// words returns the data of an image as a dense block of Depth memory words, with every word that has no data set to Fill.
+// Returns the block, the number of bytes in each word, an error if the word width or depth is not supported or the image does not fit in Depth words,
+// or a LimitExceededError if the block would be larger than MaxBytes.
+func (me MemoryOptions) words(img *Image) ([]byte, int, error) {
+
+	wb, err := memoryWordBytes(me.WordWidth)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if me.Depth < 0 {
+		return nil, 0, fmt.Errorf("Memory depth cannot be negative. Depth requested: %d", me.Depth)
+	}
+
+	end := uint64(0)
+	if n := len(img.Segments); n > 0 {
+		end = img.Segments[n-1].End()
+	}
+
+	depth := uint64(me.Depth)
+	if depth == 0 {
+		depth = (end + uint64(wb-1)) / uint64(wb)
+	}
+
+	if depth == 0 {
+		return nil, 0, fmt.Errorf("Memory depth must be at least 1 word")
+	}
+
+	// the depth is checked before multiplying by the word size, since a large depth would overflow the size of the block
+	if depth > me.maxBytes()/uint64(wb) {
+		return nil, 0, me.checkBytes(math.MaxUint64)
+	}
+
+	if end > depth*uint64(wb) {
+		return nil, 0, fmt.Errorf("Image data at address %08X does not fit in a memory of %d words", end-1, depth)
+	}
+
+	fill := make([]byte, wb)
+	unpackWord(fill, me.Fill, me.BigEndian)
+
+	data := bytes.Repeat(fill, int(depth))
+	for _, seg := range img.Segments {
+		copy(data[seg.Address:], seg.Data)
+	}
+	return data, wb, nil
+}
+
+// memoryWordBytes returns the number of bytes in each memory word of the specified width in bits.
+// A width of 0 uses 8 bit words. Returns an error if the word width is not supported.
+func memoryWordBytes(width int) (int, error) {
+
+	switch width {
+	case 0:
+		return 1, nil
+	case 8, 16, 32, 64:
+		return width / 8, nil
+	}
+	return 0, fmt.Errorf("Unsupported memory word width: %d bits. Supported widths are 8, 16, 32 and 64 bits", width)
+}
+
+// packWord returns the value of the memory word made of the bytes of b, in address order
+func packWord(b []byte, bigEndian bool) uint64 {
+
+	word := uint64(0)
+	for j := range b {
+		if bigEndian {
+			word = word<<8 | uint64(b[j])
+		} else {
+			word |= uint64(b[j]) << (8 * j)
+		}
+	}
+	return word
+}
+
+// unpackWord writes the bytes of the memory word value into b, in address order
+func unpackWord(b []byte, value uint64, bigEndian bool) {
+
+	for j := range b {
+		if bigEndian {
+			b[len(b)-1-j] = byte(value >> (8 * j))
+		} else {
+			b[j] = byte(value >> (8 * j))
+		}
+	}
+}
+
+// appendMemoryWord appends the digits of a memory word of wb bytes in the specified radix to dst.
+// Binary, octal and hexadecimal words are padded with zeros to the number of digits of the widest word. Decimal words are not padded.
+// Returns the extended slice.
+func appendMemoryWord(dst []byte, value uint64, radix int, wb int) []byte {
+
+	digits := strings.ToUpper(strconv.FormatUint(value, radix))
+
+	width := 0
+	switch radix {
+	case 2:
+		width = wb * 8
+	case 8:
+		width = (wb*8 + 2) / 3
+	case 16:
+		width = wb * 2
+	}
+
+	for i := len(digits); i < width; i++ {
+		dst = append(dst, '0')
+	}
+	return append(dst, digits...)
+}
+
+// parseMemoryWord parses the digits of a memory word of wb bytes in the specified radix.
+// Returns the value of the word, or an error if the digits are invalid or the value does not fit in the word.
+func parseMemoryWord(text []byte, radix int, wb int) (uint64, error) {
+
+	value, err := strconv.ParseUint(string(text), radix, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid base %d word '%s'", radix, text)
+	}
+
+	if wb < 8 && value>>(wb*8) != 0 {
+		return 0, fmt.Errorf("Word '%s' does not fit in %d bits", text, wb*8)
+	}
+	return value, nil
+}
+
+// memoryToken is a single token of an FPGA memory initialization file, along with the position it was found at
+type memoryToken struct {
+	text     []byte
+	line     int
+	column   int
+	lineText []byte
+}
+
+// is returns true if the text of this token is s, ignoring case
+func (me memoryToken) is(s string) bool {
+	return strings.EqualFold(string(me.text), s)
+}
+
+// memoryTokenizer splits the source of an FPGA memory initialization file into tokens.
+// Tokens are runs of letters, digits and underscores, the range operator "..", or single punctuation characters.
+// "--" line comments and "%" block comments are skipped.
+type memoryTokenizer struct {
+	src       []byte
+	pos       int
+	line      int
+	lineStart int
+}
+
+// next returns the next token of the source.
+// Returns false if the end of the source has been reached, along with a token holding the position of the end of the source.
+func (me *memoryTokenizer) next() (memoryToken, bool) {
+
+	for me.pos < len(me.src) {
+		c := me.src[me.pos]
+
+		switch {
+		case c == '\n':
+			me.pos++
+			me.newLine(me.pos)
+		case c == ' ' || c == '\t' || c == '\r':
+			me.pos++
+		case c == '-' && me.pos+1 < len(me.src) && me.src[me.pos+1] == '-':
+			me.skipLine()
+		case c == '%':
+			for me.pos++; me.pos < len(me.src) && me.src[me.pos] != '%'; me.pos++ {
+				if me.src[me.pos] == '\n' {
+					me.newLine(me.pos + 1)
+				}
+			}
+			me.pos++
+		default:
+			start := me.pos
+			if isMemoryWordChar(c) {
+				for me.pos < len(me.src) && isMemoryWordChar(me.src[me.pos]) {
+					me.pos++
+				}
+			} else if c == '.' && me.pos+1 < len(me.src) && me.src[me.pos+1] == '.' {
+				me.pos += 2
+			} else {
+				me.pos++
+			}
+			return me.token(start, me.pos), true
+		}
+	}
+
+	me.pos = len(me.src)
+	return me.token(me.pos, me.pos), false
+}
+
+// skipLine skips the rest of the current line
+func (me *memoryTokenizer) skipLine() {
+
+	for me.pos < len(me.src) && me.src[me.pos] != '\n' {
+		me.pos++
+	}
+}
+
+// newLine records the start of a new line at the specified position
+func (me *memoryTokenizer) newLine(start int) {
+
+	me.line++
+	me.lineStart = start
+}
+
+// token creates a token for the source between start and end, on the current line
+func (me *memoryTokenizer) token(start, end int) memoryToken {
+
+	lineEnd := me.lineStart + bytes.IndexByte(me.src[me.lineStart:], '\n')
+	if lineEnd < me.lineStart {
+		lineEnd = len(me.src)
+	}
+
+	return memoryToken{
+		text:     me.src[start:end],
+		line:     me.line,
+		column:   start - me.lineStart + 1,
+		lineText: bytes.TrimRight(me.src[me.lineStart:lineEnd], "\r"),
+	}
+}
+
+// expect reads the next token and checks that it is s, ignoring case.
+// Returns the token, or an InvalidRecordError if the token is something else or the end of the source has been reached.
+func (me *memoryTokenizer) expect(s string) (memoryToken, error) {
+
+	tok, ok := me.next()
+	if !ok {
+		return tok, me.errorf(tok, ErrStructure, "Expected '%s' but reached the end of the file", s)
+	}
+
+	if !tok.is(s) {
+		return tok, me.errorf(tok, ErrStructure, "Expected '%s'. Found: '%s'", s, tok.text)
+	}
+	return tok, nil
+}
+
+// errorf creates an InvalidRecordError pointing at the specified token
+func (me *memoryTokenizer) errorf(tok memoryToken, kind error, format string, args ...interface{}) error {
+	return newLineError(kind, tok.line, tok.column, tok.lineText, format, args...)
+}
+
+// isMemoryWordChar returns true if c is part of a word token of an FPGA memory initialization file
+func isMemoryWordChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// newMemoryTokenizer creates a new tokenizer for the source of an FPGA memory initialization file
+func newMemoryTokenizer(src []byte) *memoryTokenizer {
+
+	return &memoryTokenizer{
+		src:  src,
+		line: 1,
+	}
+}
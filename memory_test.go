@@ -0,0 +1,66 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadMIFRangeLimit(t *testing.T) {
+
+	src := "WIDTH=8;DEPTH=4294967296;CONTENT BEGIN [0..FFFFFFFF]:0;END;"
+
+	if _, err := ReadMIF(strings.NewReader(src), MemoryOptions{}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected the default limit to stop a range of 4 GiB, got %v", err)
+	}
+
+	// DEPTH words of 8 bytes wrap the size of the memory to 0 if it is computed before being checked
+	src = "WIDTH=64;DEPTH=2305843009213693952;CONTENT BEGIN [0..1FFFFFFFFFFFFFFF]:0;END;"
+
+	if _, err := ReadMIF(strings.NewReader(src), MemoryOptions{MaxBytes: -1}); err == nil {
+		t.Fatal("MIF memory larger than the 32 bit address space was accepted")
+	}
+
+	img, err := ReadMIF(strings.NewReader("WIDTH=16;DEPTH=1024;CONTENT BEGIN [10..1F]:1234 5678;END;"), MemoryOptions{MaxBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(img.Segments) != 1 || img.Segments[0].Address != 0x20 || len(img.Segments[0].Data) != 32 || !bytes.Equal(img.Segments[0].Data[:4], []byte{0x34, 0x12, 0x78, 0x56}) {
+		t.Fatalf("range read as %v", img.Segments)
+	}
+
+	if _, err := ReadMIF(strings.NewReader("WIDTH=16;DEPTH=1024;CONTENT BEGIN [10..1F]:1234 5678;END;"), MemoryOptions{MaxBytes: 31}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected a range of 32 bytes to exceed a limit of 31 bytes, got %v", err)
+	}
+}
+
+func TestMemoryWordsLimits(t *testing.T) {
+
+	high := &Image{Segments: []Segment{{Address: 0x08000000, Data: []byte{1, 2, 3, 4}}}}
+
+	var out bytes.Buffer
+	if err := WriteCOE(&out, high, MemoryOptions{}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected an image at 0x08000000 to exceed the default limit, got %v", err)
+	}
+
+	for _, opts := range []MemoryOptions{
+		{Depth: -1},
+		{Depth: 1 << 62, WordWidth: 64, MaxBytes: -1},
+	} {
+		if err := WriteMIF(&out, high, opts); err == nil {
+			t.Fatalf("depth %d of %d bit words was accepted", opts.Depth, opts.WordWidth)
+		}
+	}
+
+	out.Reset()
+	low := &Image{Segments: []Segment{{Address: 2, Data: []byte{0xAB}}}}
+	if err := WriteCOE(&out, low, MemoryOptions{Depth: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "00,\n00,\nAB,\n00;") {
+		t.Fatalf("unexpected COE output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,290 @@
+package ihex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// mifRadixNames maps the radix names of MIF files to their radix
+var mifRadixNames = map[string]int{
+	"BIN": 2,
+	"OCT": 8,
+	"DEC": 10,
+	"UNS": 10,
+	"HEX": 16,
+}
+
+// ReadMIF reads a Quartus MIF file and creates an image of its contents.
+// The WIDTH, DEPTH, ADDRESS_RADIX and DATA_RADIX of the memory are read from the file, so opts.WordWidth and opts.Depth are not used.
+// WIDTH must be 8, 16, 32 or 64 bits, and words are split into bytes using opts.BigEndian.
+// Content entries may set a single address ("A : V;"), a run of addresses ("A : V1 V2 V3;"), or a range of addresses ("[A..B] : V;").
+// Addresses that are not set by any entry are left out of the image.
+// Reading stops with a LimitExceededError once the entries set more than opts.MaxBytes bytes of memory.
+// Returns the new image or an InvalidRecordError pointing at the first malformed keyword, address or value.
+func ReadMIF(r io.Reader, opts MemoryOptions) (*Image, error) {
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	t := newMemoryTokenizer(src)
+	width, depth := 0, uint64(0)
+	addressRadix, dataRadix := 16, 16
+
+	// header
+	for {
+		key, ok := t.next()
+		if !ok {
+			return nil, t.errorf(key, ErrStructure, "MIF file must contain a CONTENT BEGIN section")
+		}
+
+		if key.is("CONTENT") {
+			if _, err := t.expect("BEGIN"); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		if _, err := t.expect("="); err != nil {
+			return nil, err
+		}
+
+		tok, _ := t.next()
+
+		switch {
+		case key.is("WIDTH"):
+			width, _ = strconv.Atoi(string(tok.text))
+			if _, err := memoryWordBytes(width); err != nil || width == 0 {
+				return nil, t.errorf(tok, ErrStructure, "MIF WIDTH must be 8, 16, 32 or 64 bits. Found: '%s'", tok.text)
+			}
+		case key.is("DEPTH"):
+			d, err := strconv.ParseUint(string(tok.text), 10, 64)
+			if err != nil || d == 0 {
+				return nil, t.errorf(tok, ErrStructure, "MIF DEPTH must be a positive decimal number. Found: '%s'", tok.text)
+			}
+			depth = d
+		case key.is("ADDRESS_RADIX"), key.is("DATA_RADIX"):
+			radix, ok := mifRadixNames[strings.ToUpper(string(tok.text))]
+			if !ok {
+				return nil, t.errorf(tok, ErrStructure, "MIF radix must be BIN, OCT, DEC, UNS or HEX. Found: '%s'", tok.text)
+			}
+
+			if key.is("ADDRESS_RADIX") {
+				addressRadix = radix
+			} else {
+				dataRadix = radix
+			}
+		default:
+			return nil, t.errorf(key, ErrStructure, "Unknown MIF keyword '%s'", key.text)
+		}
+
+		if _, err := t.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+
+	if width == 0 || depth == 0 {
+		return nil, t.errorf(memoryToken{line: t.line}, ErrStructure, "MIF WIDTH and DEPTH must be set before CONTENT BEGIN")
+	}
+
+	wb := width / 8
+	word := make([]byte, wb)
+	total := uint64(0)
+
+	// the depth is checked before multiplying by the word size, since a large DEPTH would overflow the product
+	if depth > (1<<32)/uint64(wb) {
+		return nil, fmt.Errorf("MIF memory of %d words does not fit in the 32 bit address space", depth)
+	}
+
+	// parseAddress parses a single word address and checks that it is inside the memory
+	parseAddress := func(tok memoryToken) (uint64, error) {
+
+		a, err := strconv.ParseUint(string(tok.text), addressRadix, 64)
+		if err != nil {
+			return 0, t.errorf(tok, ErrBadHexDigit, "Invalid base %d address '%s'", addressRadix, tok.text)
+		}
+
+		if a >= depth {
+			return 0, t.errorf(tok, ErrStructure, "Word address %X is past the memory depth of %d words", a, depth)
+		}
+		return a, nil
+	}
+
+	// content
+	for {
+		tok, ok := t.next()
+		if !ok {
+			return nil, t.errorf(tok, ErrStructure, "MIF content must end with END;")
+		}
+
+		if tok.is("END") {
+			if _, err := t.expect(";"); err != nil {
+				return nil, err
+			}
+			return img, nil
+		}
+
+		isRange := tok.is("[")
+		if isRange {
+			tok, _ = t.next()
+		}
+
+		first, err := parseAddress(tok)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+
+		if isRange {
+			if _, err := t.expect(".."); err != nil {
+				return nil, err
+			}
+
+			tok, _ = t.next()
+			if last, err = parseAddress(tok); err != nil {
+				return nil, err
+			}
+
+			if last < first {
+				return nil, t.errorf(tok, ErrStructure, "MIF address range must not end before it starts")
+			}
+
+			if _, err := t.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := t.expect(":"); err != nil {
+			return nil, err
+		}
+
+		values := make([]uint64, 0, 1)
+		for {
+			tok, ok := t.next()
+			if !ok {
+				return nil, t.errorf(tok, ErrStructure, "MIF content entry must end with ';'")
+			}
+
+			if tok.is(";") {
+				break
+			}
+
+			negative := dataRadix == 10 && tok.is("-")
+			if negative {
+				tok, _ = t.next()
+			}
+
+			value, err := parseMemoryWord(tok.text, dataRadix, wb)
+			if err != nil {
+				return nil, t.errorf(tok, ErrBadHexDigit, "%s", err.Error())
+			}
+
+			if negative {
+				value = -value
+				if wb < 8 {
+					value &= 1<<(wb*8) - 1
+				}
+			}
+			values = append(values, value)
+		}
+
+		if len(values) == 0 {
+			return nil, t.errorf(tok, ErrStructure, "MIF content entry must contain at least one value")
+		}
+
+		// a single address takes each value at consecutive addresses, and a range repeats the values until it is filled
+		if !isRange {
+			last = first + uint64(len(values)) - 1
+			if last >= depth {
+				return nil, t.errorf(tok, ErrStructure, "MIF content entry extends past the memory depth of %d words", depth)
+			}
+		}
+
+		// last is inside the memory, which fits in the 32 bit address space, so the size of the entry cannot overflow
+		size := (last - first + 1) * uint64(wb)
+		total += size
+		if err := opts.checkBytes(total); err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, 0, size)
+		for a := first; a <= last; a++ {
+			unpackWord(word, values[(a-first)%uint64(len(values))], opts.BigEndian)
+			data = append(data, word...)
+		}
+		img.Set(uint32(first*uint64(wb)), data)
+	}
+}
+
+// WriteMIF writes the data of an image to the provided writer as a Quartus MIF file.
+// Every word of the memory, from word address 0 to opts.Depth, is written in the radix set by opts.Radix, with hexadecimal addresses.
+// Runs of equal words are written as address ranges. Words that have no data in the image are written as opts.Fill.
+// Returns an error if the options are invalid or the image does not fit in opts.Depth words, a LimitExceededError if the memory is larger than opts.MaxBytes,
+// or any errors generated by the provided writer.
+func WriteMIF(w io.Writer, img *Image, opts MemoryOptions) error {
+
+	radix := opts.radix()
+
+	radixName := ""
+	for name, r := range mifRadixNames {
+		if r == radix && name != "DEC" {
+			radixName = name
+		}
+	}
+
+	if radixName == "" {
+		return fmt.Errorf("Unsupported MIF radix: %d. Supported radixes are 2, 8, 10 and 16", radix)
+	}
+
+	data, wb, err := opts.words(img)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "WIDTH=%d;\nDEPTH=%d;\n\nADDRESS_RADIX=HEX;\nDATA_RADIX=%s;\n\nCONTENT BEGIN\n", wb*8, len(data)/wb, radixName); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, 96)
+	for i := 0; i < len(data); {
+		value := packWord(data[i:i+wb], opts.BigEndian)
+
+		end := i + wb
+		for end < len(data) && packWord(data[end:end+wb], opts.BigEndian) == value {
+			end += wb
+		}
+
+		buf = append(buf[:0], '\t')
+		if first, last := i/wb, end/wb-1; first == last {
+			buf = appendMemoryWord(buf, uint64(first), 16, 0)
+		} else {
+			buf = append(buf, '[')
+			buf = appendMemoryWord(buf, uint64(first), 16, 0)
+			buf = append(buf, ".."...)
+			buf = appendMemoryWord(buf, uint64(last), 16, 0)
+			buf = append(buf, ']')
+		}
+		buf = append(buf, " : "...)
+		buf = appendMemoryWord(buf, value, radix, wb)
+		buf = append(buf, ";\n"...)
+
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+		i = end
+	}
+
+	if _, err := bw.WriteString("END;\n"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
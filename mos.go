@@ -0,0 +1,183 @@
+package ihex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// mosStartChar is the character that starts a MOS Technology hex record
+	mosStartChar = ';'
+
+	// mosHeaderSize is the number of characters in a MOS Technology hex record before its data: start character, byte count and address
+	mosHeaderSize = 1 + 2 + 4
+
+	// mosChecksumSize is the number of characters in the checksum at the end of a MOS Technology hex record
+	mosChecksumSize = 4
+
+	// mosBytesPerRecord is the number of data bytes written in each MOS Technology hex record
+	mosBytesPerRecord = 24
+
+	// mosAddressSpace is the number of bytes addressable by the 16 bit addresses of MOS Technology hex records
+	mosAddressSpace = 0x10000
+
+	// mosXOFF is the XOFF control character that some tools write at the end of MOS Technology hex files
+	mosXOFF = 0x13
+)
+
+// ReadMOS reads a MOS Technology hex file and creates an image of its contents.
+// Each record is ";NNAAAADD...DDCCCC": a byte count, a 16 bit address, the data and a 16 bit checksum of the sum of every byte before it.
+// The file ends with a record with a byte count of 0 whose address field holds the number of data records in the file.
+// Addresses are 16 bits, so the image always fits in an I8HEX file.
+// Returns the new image or an InvalidRecordError pointing at the first malformed record.
+func ReadMOS(r io.Reader) (*Image, error) {
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	scanner := bufio.NewScanner(r)
+	buf := make([]byte, 0, recordMaximumDataSize+mosHeaderSize)
+	records := uint64(0)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimRight(scanner.Bytes(), "\x00\x13")
+
+		if len(text) == 0 {
+			continue
+		}
+
+		if text[0] != mosStartChar {
+			return nil, newLineError(ErrBadStartCode, line, 1, text, "MOS Technology hex record must begin with '%c'. Record starts with: '%c'", mosStartChar, text[0])
+		}
+
+		recordBytes, pos, err := decodeHex(buf[:0], text[1:])
+		buf = recordBytes
+
+		if err != nil {
+			return nil, newLineError(ErrBadHexDigit, line, pos+2, text, "Unable to decode hexadecimal record contents: %s", err.Error())
+		}
+
+		if len(recordBytes) < (mosHeaderSize-1+mosChecksumSize)/2 {
+			return nil, newLineError(ErrLengthMismatch, line, len(text)+1, text, "MOS Technology hex record must be at least %d characters long", mosHeaderSize+mosChecksumSize)
+		}
+
+		count := int(recordBytes[0])
+		if actual := len(recordBytes) - (mosHeaderSize-1+mosChecksumSize)/2; count != actual {
+			return nil, newLineError(ErrLengthMismatch, line, 2, text, "Record byte count (%d) does not match actual detected byte count (%d)", count, actual)
+		}
+
+		address := uint16(recordBytes[1])<<8 | uint16(recordBytes[2])
+		checksum := uint16(recordBytes[len(recordBytes)-2])<<8 | uint16(recordBytes[len(recordBytes)-1])
+
+		if sum := mosChecksum(recordBytes[:len(recordBytes)-2]); sum != checksum {
+			return nil, newLineError(ErrChecksumMismatch, line, len(text)-mosChecksumSize+1, text, "Record checksum '%04X' does not match computed checksum '%04X'", checksum, sum)
+		}
+
+		if count == 0 {
+			if uint64(address) != records%mosAddressSpace {
+				return nil, newLineError(ErrStructure, line, 4, text, "Final record count (%d) does not match the number of data records in the file (%d)", address, records)
+			}
+			return img, nil
+		}
+
+		if int(address)+count > mosAddressSpace {
+			return nil, newLineError(ErrStructure, line, 4, text, "Record data extends past the end of the 16 bit address space")
+		}
+
+		img.Set(uint32(address), recordBytes[3:3+count])
+		records++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, newLineError(ErrStructure, line+1, 0, nil, "MOS Technology hex file must end with a record with a byte count of 0")
+}
+
+// WriteMOS writes the data of an image to the provided writer in MOS Technology hex format.
+// Data is written in records of 24 bytes, followed by the final record holding the number of data records written.
+// The start address of the image is not written, since MOS Technology hex files have no way to store it.
+// Returns an error if the image does not fit in the 16 bit address space of MOS Technology hex files or any errors generated by the provided writer.
+func WriteMOS(w io.Writer, img *Image) error {
+
+	if n := len(img.Segments); n > 0 && img.Segments[n-1].End() > mosAddressSpace {
+		return fmt.Errorf("Image data at address %08X does not fit in the 16 bit address space of MOS Technology hex files", img.Segments[n-1].End()-1)
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, mosHeaderSize+mosBytesPerRecord*2+mosChecksumSize+1)
+	records := 0
+
+	for _, seg := range img.Segments {
+		for i := 0; i < len(seg.Data); i += mosBytesPerRecord {
+			end := i + mosBytesPerRecord
+			if end > len(seg.Data) {
+				end = len(seg.Data)
+			}
+
+			buf = appendMOSRecord(buf[:0], uint16(seg.Address+uint32(i)), seg.Data[i:end])
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+			records++
+		}
+	}
+
+	if _, err := bw.Write(appendMOSRecord(buf[:0], uint16(records), nil)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ReadMOSFile reads a MOS Technology hex file and converts it to an I8HEX file with records of 16 bytes.
+// Returns the new I8HEX file or an InvalidRecordError pointing at the first malformed record.
+func ReadMOSFile(r io.Reader) (*I8HEXFile, error) {
+	return newImageFile[i8hexType](ReadMOS(r))
+}
+
+// WriteMOSFile converts an IHEX file to MOS Technology hex format and writes it to the provided writer.
+// Returns an error if the file's data does not fit in a 16 bit address space or any errors generated by the provided writer.
+func WriteMOSFile(w io.Writer, f File) error {
+
+	img, err := NewImage(f)
+	if err != nil {
+		return err
+	}
+	return WriteMOS(w, img)
+}
+
+// appendMOSRecord appends a MOS Technology hex record, including its line ending, to dst.
+// Returns the extended slice.
+func appendMOSRecord(dst []byte, address uint16, data []byte) []byte {
+
+	header := [3]byte{byte(len(data)), byte(address >> 8), byte(address)}
+	sum := mosChecksum(header[:]) + mosChecksum(data)
+
+	dst = append(dst, mosStartChar)
+	for _, b := range header {
+		dst = appendHexByte(dst, recordHexDigits, b)
+	}
+	for _, b := range data {
+		dst = appendHexByte(dst, recordHexDigits, b)
+	}
+	dst = appendHexByte(dst, recordHexDigits, byte(sum>>8))
+	dst = appendHexByte(dst, recordHexDigits, byte(sum))
+
+	return append(dst, recordLineEnding...)
+}
+
+// mosChecksum returns the 16 bit sum of the bytes in b
+func mosChecksum(b []byte) uint16 {
+
+	sum := uint16(0)
+	for _, v := range b {
+		sum += uint16(v)
+	}
+	return sum
+}
@@ -0,0 +1,154 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteMOS(t *testing.T) {
+
+	img := &Image{Segments: []Segment{{Address: 0x1000, Data: []byte{1, 2, 3}}}}
+
+	var buf bytes.Buffer
+	if err := WriteMOS(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// the final record holds the count of data records in its address field
+	if want := ";0310000102030019\n;0000010001\n"; buf.String() != want {
+		t.Fatalf("wrote %q, expected %q", buf.String(), want)
+	}
+}
+
+func TestMOSChecksum(t *testing.T) {
+
+	// the 16 bit checksum of a full record of FF bytes carries into its upper byte: 18 + FF + 00 + 24 * FF = 18FF
+	img := &Image{Segments: []Segment{{Address: 0xFF00, Data: bytes.Repeat([]byte{0xFF}, mosBytesPerRecord)}}}
+
+	var buf bytes.Buffer
+	if err := WriteMOS(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := ";18FF00" + strings.Repeat("FF", mosBytesPerRecord) + "18FF\n;0000010001\n"; buf.String() != want {
+		t.Fatalf("wrote %q, expected %q", buf.String(), want)
+	}
+
+	read, err := ReadMOS(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, read, img)
+
+	// a checksum that only matches the lower byte of the sum is rejected
+	_, err = ReadMOS(bytes.NewBufferString(";18FF00" + strings.Repeat("FF", mosBytesPerRecord) + "00FF\n;0000010001\n"))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected %v, got %v", ErrChecksumMismatch, err)
+	}
+}
+
+func TestReadMOS(t *testing.T) {
+
+	// lowercase digits, CRLF line endings, blank lines and the trailing NUL and XOFF characters written by some tools are accepted
+	src := ";0310000102030019\r\n\r\n;011010ff0120\r\n;0000020002\r\n\x00\x13"
+
+	img, err := ReadMOS(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImage(t, img, &Image{Segments: []Segment{
+		{Address: 0x1000, Data: []byte{1, 2, 3}},
+		{Address: 0x1010, Data: []byte{0xFF}},
+	}})
+}
+
+func TestReadMOSErrors(t *testing.T) {
+
+	for _, c := range []struct {
+		name   string
+		src    string
+		kind   error
+		line   int
+		column int
+	}{
+		{"StartChar", ":0310000102030019\n;0000010001\n", ErrBadStartCode, 1, 1},
+		{"BadDigit", ";03100001020G0019\n;0000010001\n", ErrBadHexDigit, 1, 13},
+		{"Short", ";000000\n", ErrLengthMismatch, 1, 8},
+		{"ByteCount", ";0410000102030019\n;0000010001\n", ErrLengthMismatch, 1, 2},
+		{"Checksum", ";0310000102030018\n;0000010001\n", ErrChecksumMismatch, 1, 14},
+		{"RecordCount", ";0310000102030019\n;0000020002\n", ErrStructure, 2, 4},
+		{"NoCount", ";0310000102030019\n;0000000000\n", ErrStructure, 2, 4},
+		{"AddressSpace", ";02FFFF0102" + "0203\n;0000010001\n", ErrStructure, 1, 4},
+		{"NoEnd", ";0310000102030019\n", ErrStructure, 2, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ReadMOS(bytes.NewBufferString(c.src))
+
+			var e *InvalidRecordError
+			if !errors.Is(err, c.kind) || !errors.As(err, &e) || e.Line != c.line || e.Column != c.column {
+				t.Fatalf("expected %v at line %d column %d, got %v", c.kind, c.line, c.column, err)
+			}
+		})
+	}
+}
+
+func TestMOSFileConversion(t *testing.T) {
+
+	var src bytes.Buffer
+	if err := WriteMOS(&src, &Image{Segments: []Segment{{Address: 0x1000, Data: testData(40)}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ReadMOSFile(&src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.GetType() != I8HEX {
+		t.Fatalf("MOS file was converted to type %d", f.GetType())
+	}
+
+	// 40 bytes are written as data records of 16, 16 and 8 bytes, followed by the EOF record
+	want := []Record{
+		{Type: RecordData, AddressOffset: 0x1000, Data: testData(40)[:16]},
+		{Type: RecordData, AddressOffset: 0x1010, Data: testData(40)[16:32]},
+		{Type: RecordData, AddressOffset: 0x1020, Data: testData(40)[32:]},
+		{Type: RecordEOF, Data: []byte{}},
+	}
+
+	checkRecords(t, f, want)
+
+	var out bytes.Buffer
+	if err := WriteMOSFile(&out, f); err != nil {
+		t.Fatal(err)
+	}
+
+	var again bytes.Buffer
+	if err := WriteMOS(&again, &Image{Segments: []Segment{{Address: 0x1000, Data: testData(40)}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != again.String() {
+		t.Fatalf("I8HEX file was written as:\n%s\nexpected:\n%s", out.String(), again.String())
+	}
+}
+
+func TestWriteMOSAddressSpace(t *testing.T) {
+
+	// I32HEX data above 64 KiB cannot be written to a MOS Technology hex file
+	f := NewI32HEXFile()
+	if err := f.AddRecords(
+		Record{Type: RecordExtLinear, Data: []byte{0x00, 0x01}},
+		Record{Type: RecordData, Data: []byte{1}},
+		Record{Type: RecordEOF, Data: []byte{}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteMOSFile(&bytes.Buffer{}, f); err == nil {
+		t.Fatal("writing data above 64 KiB did not fail")
+	}
+}
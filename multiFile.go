@@ -0,0 +1,79 @@
+package ihex
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+)
+
+// NewFiles reads the provided reader and creates a separate IHEX file for each image in it.
+// Some tools concatenate several HEX images into one file. Each image ends with its own EOF record, so a new file is started after every EOF record.
+// Records following the last EOF record form a final file of their own.
+// The IHEX file format of each file is determined independently from the record types of its image.
+// Returns the new IHEX files, in order, or an error if any errors were encountered during reading.
+func NewFiles(r io.Reader) ([]File, error) {
+	return NewFilesWithOptions(context.Background(), r, ParseOptions{})
+}
+
+// NewFilesWithOptions is equivalent to NewFiles, using the provided options to control reading.
+// If RejectDataAfterEOF is set, any record after the first EOF record is an error, so at most one file is returned.
+func NewFilesWithOptions(ctx context.Context, r io.Reader, opts ParseOptions) ([]File, error) {
+
+	records, positions, err := readAllRecords(ctx, r, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, 1)
+	start := 0
+
+	for i := 0; i < len(records); i++ {
+		if records[i].Type != RecordEOF && i < len(records)-1 {
+			continue
+		}
+
+		f, err := newFileFromRecords(records[start:i+1], positions[start:i+1])
+
+		if err != nil {
+			if e, ok := err.(*IndexedRecordError); ok {
+				e.Index += start
+			}
+			return nil, err
+		}
+
+		files = append(files, f)
+		start = i + 1
+	}
+
+	return files, nil
+}
+
+// WriteFiles writes each IHEX file to the provided writer, in order, one after another.
+// Each file is written in the same way as WriteFile, so each image keeps its own EOF record.
+// Returns any errors generated by the provided writer during the writing process.
+func WriteFiles(w io.Writer, files ...File) error {
+	return WriteFilesWithOptions(context.Background(), w, WriteOptions{}, files...)
+}
+
+// WriteFilesWithOptions is equivalent to WriteFiles, using the provided options to control the formatting of each file.
+func WriteFilesWithOptions(ctx context.Context, w io.Writer, opts WriteOptions, files ...File) error {
+
+	// every file goes into a single gzip stream, rather than one stream per file
+	if opts.Gzip {
+		gz := gzip.NewWriter(w)
+		opts.Gzip = false
+
+		if err := WriteFilesWithOptions(ctx, gz, opts, files...); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+
+	for _, f := range files {
+		if err := WriteFileWithOptions(ctx, f, w, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
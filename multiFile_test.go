@@ -0,0 +1,125 @@
+package ihex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// multiFileSource is three images of different file types followed by records with no EOF record
+const multiFileSource = ":02000000ABCD86\n:00000001FF\n" +
+	":020000021000EC\n:0100000001FE\n:00000001FF\n" +
+	":020000040001F9\n:0100000002FD\n:0400000500000100F6\n:00000001FF\n" +
+	":0100200003DC\n"
+
+func TestNewFiles(t *testing.T) {
+
+	files, err := NewFiles(bytes.NewBufferString(multiFileSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		fileType  FileType
+		records   int
+		firstLine int
+		address   uint32
+	}{
+		{I8HEX, 2, 1, 0x00000},
+		{I16HEX, 3, 3, 0x10000},
+		{I32HEX, 4, 6, 0x10000},
+		{I8HEX, 1, 10, 0x00020},
+	}
+
+	if len(files) != len(want) {
+		t.Fatalf("read %d files, expected %d", len(files), len(want))
+	}
+
+	for i, w := range want {
+		f := files[i]
+
+		if f.GetType() != w.fileType || f.Len() != w.records {
+			t.Fatalf("file %d is type %d with %d records, expected type %d with %d records", i, f.GetType(), f.Len(), w.fileType, w.records)
+		}
+
+		// positions still count lines from the start of the whole input
+		if pos, ok := f.Position(0); !ok || pos.Line != w.firstLine {
+			t.Fatalf("file %d starts at line %d, expected line %d", i, pos.Line, w.firstLine)
+		}
+
+		img, err := NewImage(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(img.Segments) != 1 || img.Segments[0].Address != w.address {
+			t.Fatalf("file %d does not hold its data at %08X", i, w.address)
+		}
+	}
+}
+
+func TestNewFilesErrorIndex(t *testing.T) {
+
+	// the second image is I16HEX, so its extended linear address record is an error at record 3 of the whole input
+	src := ":02000000ABCD86\n:00000001FF\n:020000021000EC\n:020000040000FA\n:00000001FF\n"
+
+	_, err := NewFiles(bytes.NewBufferString(src))
+
+	var e *IndexedRecordError
+	if !errors.As(err, &e) || e.Index != 3 || !errors.Is(err, ErrInvalidRecordType) {
+		t.Fatalf("expected %v at index 3, got %v", ErrInvalidRecordType, err)
+	}
+
+	var re *InvalidRecordError
+	if !errors.As(err, &re) || re.Line != 4 {
+		t.Fatalf("expected the error at line 4, got %v", err)
+	}
+}
+
+func TestNewFilesRejectDataAfterEOF(t *testing.T) {
+
+	if _, err := NewFilesWithOptions(context.Background(), bytes.NewBufferString(multiFileSource), ParseOptions{RejectDataAfterEOF: true}); !errors.Is(err, ErrStructure) {
+		t.Fatalf("expected %v, got %v", ErrStructure, err)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+
+	files, err := NewFiles(bytes.NewBufferString(multiFileSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFiles(&buf, files...); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != multiFileSource {
+		t.Fatalf("wrote:\n%s\nexpected:\n%s", buf.String(), multiFileSource)
+	}
+
+	// every file is written into one gzip stream
+	var compressed bytes.Buffer
+	if err := WriteFilesWithOptions(context.Background(), &compressed, WriteOptions{Gzip: true}, files...); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz.Multistream(false)
+
+	out, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != multiFileSource || compressed.Len() != 0 {
+		t.Fatalf("files were not written as a single gzip stream:\n%s", out)
+	}
+}
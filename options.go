@@ -0,0 +1,103 @@
+package ihex
+
+// ProgressFunc is a callback that reports the progress of reading or writing a HEX file.
+// records is the number of records processed so far.
+// bytes is the number of bytes read from (or written to) the underlying stream so far.
+type ProgressFunc func(records int, bytes int64)
+
+// ParseOptions defines optional settings for reading HEX files.
+// The zero value is ready to use and matches the behavior of NewFile.
+// Reading stops with a LimitExceededError as soon as any of the Max limits is crossed.
+//
+// When Concurrency is greater than 1, the limits are checked as the decoded chunks are put back in order, not as each line is read.
+// MaxInputBytes, MaxRecords and MaxDataBytes also stop the goroutine reading the input at the line that crosses them,
+// but MaxAddressSpan and RejectDataAfterEOF depend on the records before them, so up to about 2×Concurrency chunks of ChunkSize lines
+// may be read and decoded past the record that crosses them before reading stops.
+type ParseOptions struct {
+	// Progress is called after each record is read, if not nil.
+	// When records are parsed concurrently, it is called once per chunk of records instead.
+	Progress ProgressFunc
+
+	// Concurrency is the number of goroutines used to decode and validate records.
+	// Values of 1 or less parse records one at a time on the calling goroutine.
+	Concurrency int
+
+	// ChunkSize is the number of lines handed to a goroutine at a time when Concurrency is greater than 1.
+	// Values of 0 or less use a default chunk size.
+	ChunkSize int
+
+	// MaxInputBytes is the maximum number of bytes read from the input. Values of 0 or less mean no limit.
+	// For compressed input, the limit applies to the decompressed bytes.
+	MaxInputBytes int64
+
+	// MaxRecords is the maximum number of records read. Values of 0 or less mean no limit.
+	MaxRecords int64
+
+	// MaxDataBytes is the maximum total number of data bytes across all records. Values of 0 or less mean no limit.
+	MaxDataBytes int64
+
+	// MaxAddressSpan is the maximum distance between the lowest and highest data address of the file. Values of 0 or less mean no limit.
+	MaxAddressSpan int64
+
+	// RejectDataAfterEOF makes any record following an EOF record an error matching ErrStructure.
+	// By default, reading continues past EOF records.
+	RejectDataAfterEOF bool
+}
+
+// EOFMode defines how EOF records are handled when writing a HEX file
+type EOFMode byte
+
+const (
+	// EOFPreserve writes the EOF records of a file exactly where they are found in the file
+	EOFPreserve EOFMode = iota
+
+	// EOFEnforceSingle skips all EOF records of a file and writes a single EOF record at the end of the output
+	EOFEnforceSingle
+
+	// EOFOmit skips all EOF records, so the output contains none
+	EOFOmit
+)
+
+// writerDefaultBufferSize is the size of the output buffer used when WriteOptions does not specify one
+const writerDefaultBufferSize = 4096
+
+// WriteOptions defines optional settings for writing HEX files.
+// The zero value is ready to use and matches the behavior of WriteFile.
+type WriteOptions struct {
+	// Progress is called after each record is written, if not nil
+	Progress ProgressFunc
+
+	// LineEnding is written after each record. An empty string uses "\n".
+	LineEnding string
+
+	// Lowercase writes hexadecimal digits in lowercase instead of uppercase
+	Lowercase bool
+
+	// EOF controls how EOF records are written
+	EOF EOFMode
+
+	// OmitStartRecords skips RecordStartSegment and RecordStartLinear records when writing
+	OmitStartRecords bool
+
+	// BufferSize is the size of the output buffer in bytes. Values of 0 or less use a default buffer size.
+	BufferSize int
+
+	// AddressUnit is the number of bytes addressed by each step of a record's address, for word-addressed HEX files: 1, 2 or 4.
+	// Record address offsets and extended address records written by a FileWriter count words of AddressUnit bytes instead of bytes.
+	// A value of 0 writes byte addresses. WriteFile writes records as they are, so it does not use this option.
+	AddressUnit int
+
+	// Gzip compresses the output with gzip
+	Gzip bool
+}
+
+// skip returns true if these options exclude a record from being written
+func (me WriteOptions) skip(r Record) bool {
+
+	if r.Type == RecordEOF {
+		return me.EOF != EOFPreserve
+	} else if r.Type == RecordStartSegment || r.Type == RecordStartLinear {
+		return me.OmitStartRecords
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package ihex
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestWriteOptions(t *testing.T) {
+
+	f := NewI32HEXFile()
+	if err := f.AddRecords(
+		Record{Type: RecordData, AddressOffset: 0x0000, Data: []byte{0xAB, 0xCD}},
+		Record{Type: RecordStartLinear, Data: []byte{0x00, 0x00, 0x01, 0x00}},
+		Record{Type: RecordEOF, Data: []byte{}},
+		Record{Type: RecordData, AddressOffset: 0x0010, Data: []byte{0x01}},
+		Record{Type: RecordEOF, Data: []byte{}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		opts WriteOptions
+		want string
+	}{
+		{"Default", WriteOptions{}, ":02000000ABCD86\n:0400000500000100F6\n:00000001FF\n:0100100001EE\n:00000001FF\n"},
+		{"LineEnding", WriteOptions{LineEnding: "\r\n"}, ":02000000ABCD86\r\n:0400000500000100F6\r\n:00000001FF\r\n:0100100001EE\r\n:00000001FF\r\n"},
+		{"Lowercase", WriteOptions{Lowercase: true}, ":02000000abcd86\n:0400000500000100f6\n:00000001ff\n:0100100001ee\n:00000001ff\n"},
+		{"EOFPreserve", WriteOptions{EOF: EOFPreserve}, ":02000000ABCD86\n:0400000500000100F6\n:00000001FF\n:0100100001EE\n:00000001FF\n"},
+		{"EOFEnforceSingle", WriteOptions{EOF: EOFEnforceSingle}, ":02000000ABCD86\n:0400000500000100F6\n:0100100001EE\n:00000001FF\n"},
+		{"EOFOmit", WriteOptions{EOF: EOFOmit}, ":02000000ABCD86\n:0400000500000100F6\n:0100100001EE\n"},
+		{"OmitStartRecords", WriteOptions{OmitStartRecords: true}, ":02000000ABCD86\n:00000001FF\n:0100100001EE\n:00000001FF\n"},
+		{"Combined", WriteOptions{LineEnding: "\r", Lowercase: true, EOF: EOFEnforceSingle, OmitStartRecords: true, BufferSize: 1}, ":02000000abcd86\r:0100100001ee\r:00000001ff\r"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteFileWithOptions(context.Background(), f, &buf, c.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != c.want {
+				t.Fatalf("wrote %q, expected %q", buf.String(), c.want)
+			}
+		})
+	}
+}
+
+func TestFileWriterOptions(t *testing.T) {
+
+	for _, c := range []struct {
+		name string
+		opts WriteOptions
+		want string
+	}{
+		{"Default", WriteOptions{}, ":02000000ABCD86\n:00000001FF\n"},
+		{"LineEnding", WriteOptions{LineEnding: "\r\n"}, ":02000000ABCD86\r\n:00000001FF\r\n"},
+		{"Lowercase", WriteOptions{Lowercase: true}, ":02000000abcd86\n:00000001ff\n"},
+
+		// a FileWriter only ever writes a single EOF record, so EOFPreserve and EOFEnforceSingle write the same output
+		{"EOFEnforceSingle", WriteOptions{EOF: EOFEnforceSingle}, ":02000000ABCD86\n:00000001FF\n"},
+		{"EOFOmit", WriteOptions{EOF: EOFOmit}, ":02000000ABCD86\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			fw, err := NewFileWriterOptions(&buf, 16, I32HEX, c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := fw.Write([]byte{0xAB, 0xCD}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != c.want {
+				t.Fatalf("wrote %q, expected %q", buf.String(), c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package ihex
+
+// Position is the location of a record in the source it was read from.
+// Line is the 1-based line number of the record and Offset is the 0-based byte offset of the start of that line.
+type Position struct {
+	Line   int
+	Offset int64
+}
+
+// IsValid returns true if this position refers to a location in a source.
+// Records that were added to a file rather than read from a source have no valid position.
+func (me Position) IsValid() bool {
+	return me.Line > 0
+}
@@ -0,0 +1,129 @@
+package ihex
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// positionSource is three records with CRLF line endings, starting at offsets 0, 17 and 32
+const positionSource = ":02000000ABCD86\r\n:0100100001EE\r\n:00000001FF\r\n"
+
+// positionWant is the position of each record of positionSource
+var positionWant = []Position{{Line: 1, Offset: 0}, {Line: 2, Offset: 17}, {Line: 3, Offset: 32}}
+
+// checkPositions fails the test if the positions of the records of a file are not the expected positions
+func checkPositions(t *testing.T, f File, want []Position) {
+
+	t.Helper()
+
+	for i, w := range want {
+		pos, ok := f.Position(i)
+		if pos != w || ok != w.IsValid() {
+			t.Fatalf("record %d is at %+v (%t), expected %+v", i, pos, ok, w)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+
+	for _, c := range []struct {
+		name string
+		opts ParseOptions
+	}{
+		{"Sequential", ParseOptions{}},
+		{"Concurrent", ParseOptions{Concurrency: 2, ChunkSize: 1}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f, err := NewFileWithOptions(context.Background(), bytes.NewBufferString(positionSource), c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPositions(t, f, positionWant)
+		})
+	}
+
+	rr := NewRecordReader(bytes.NewBufferString(positionSource))
+	for i := 0; ; i++ {
+		if _, err := rr.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr.Position() != positionWant[i] {
+			t.Fatalf("record reader read record %d at %+v, expected %+v", i, rr.Position(), positionWant[i])
+		}
+	}
+}
+
+func TestPositionsInsertRemove(t *testing.T) {
+
+	f, err := NewFile(bytes.NewBufferString(positionSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an inserted record has no position, and the records after it keep theirs
+	if err := f.Insert(1, Record{Type: RecordData, AddressOffset: 0x0008, Data: []byte{0xEE}}); err != nil {
+		t.Fatal(err)
+	}
+	checkPositions(t, f, []Position{positionWant[0], {}, positionWant[1], positionWant[2]})
+
+	if i, ok := f.FindAddress(0x0010); !ok || i != 2 {
+		t.Fatalf("address 0010 found in record %d (%t), expected record 2", i, ok)
+	}
+
+	if i, ok := f.FindAddress(0x0008); !ok || i != 1 {
+		t.Fatalf("address 0008 found in record %d (%t), expected record 1", i, ok)
+	}
+
+	if err := f.Remove(0); err != nil {
+		t.Fatal(err)
+	}
+	checkPositions(t, f, []Position{{}, positionWant[1], positionWant[2]})
+
+	if i, ok := f.FindAddress(0x0010); !ok || i != 1 {
+		t.Fatalf("address 0010 found in record %d (%t), expected record 1", i, ok)
+	}
+
+	pos, _ := f.Position(1)
+	if pos != positionWant[1] {
+		t.Fatalf("record holding address 0010 is at %+v, expected %+v", pos, positionWant[1])
+	}
+
+	if _, ok := f.FindAddress(0x0000); ok {
+		t.Fatal("address 0000 was found after its record was removed")
+	}
+
+	// a file read as I8HEX keeps rejecting records of the other file types
+	if err := f.Insert(0, Record{Type: RecordExtSegment, Data: []byte{0x10, 0x00}}); err == nil {
+		t.Fatal("inserting an extended segment address record into an I8HEX file did not fail")
+	}
+
+	if _, ok := f.Position(f.Len()); ok {
+		t.Fatal("position of an index past the end of the file was found")
+	}
+}
+
+func TestFindAddressExtended(t *testing.T) {
+
+	f, err := NewFile(bytes.NewBufferString(":020000040001F9\r\n" + positionSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := f.FindAddress(0x0010); ok {
+		t.Fatal("address 0010 was found below the extended linear address")
+	}
+
+	i, ok := f.FindAddress(0x10010)
+	if !ok || i != 2 {
+		t.Fatalf("address 10010 found in record %d (%t), expected record 2", i, ok)
+	}
+
+	if pos, _ := f.Position(i); pos.Line != 3 || pos.Offset != 17+17 {
+		t.Fatalf("record holding address 10010 is at %+v, expected line 3 at offset 34", pos)
+	}
+}
@@ -1,12 +1,6 @@
 package ihex
 
-import (
-	"bytes"
-	"encoding/hex"
-	"fmt"
-	"io"
-	"strings"
-)
+import "fmt"
 
 const (
 	// recordMaximumDataSize the largest size of the data payload of a record (in bytes)
@@ -15,9 +9,6 @@ const (
 	// recordMaximumSizeChars the largest size of a record (including header data, checksum and starting character) when hexadecimal encoded
 	recordMaximumSizeChars = 521
 
-	// recordEOFChecksum is the default checksum value for an EOF record
-	recordEOFChecksum = 0xFF
-
 	// recordStartChar the starting character of a HEX record
 	recordStartChar = ':'
 
@@ -29,8 +20,37 @@ const (
 
 	// recordHeaderAndChecksumSize defines how many bytes everythign in the record except the starting char and the data itself takes up
 	recordHeaderAndChecksumSize = 5
+
+	// recordHexDigits is the lookup table used to encode record bytes as uppercase hexadecimal characters
+	recordHexDigits = "0123456789ABCDEF"
+
+	// recordLowerHexDigits is the lookup table used to encode record bytes as lowercase hexadecimal characters
+	recordLowerHexDigits = "0123456789abcdef"
+
+	// recordLineEnding is the default line ending written after each record
+	recordLineEnding = "\n"
+
+	// recordInvalidHexDigit marks characters that are not hexadecimal digits in the decoding lookup table
+	recordInvalidHexDigit = 0xFF
 )
 
+// recordHexValues is the lookup table used to decode hexadecimal characters into their 4 bit values.
+// Characters that are not hexadecimal digits map to recordInvalidHexDigit.
+var recordHexValues [256]byte
+
+func init() {
+	for i := range recordHexValues {
+		recordHexValues[i] = recordInvalidHexDigit
+	}
+	for i := 0; i < 10; i++ {
+		recordHexValues['0'+i] = byte(i)
+	}
+	for i := 0; i < 6; i++ {
+		recordHexValues['A'+i] = byte(10 + i)
+		recordHexValues['a'+i] = byte(10 + i)
+	}
+}
+
 // Record is a single record in an Intel HEX file.
 // An IHEX record contains the following:
 // The record type.
@@ -53,38 +73,84 @@ func (me Record) validate(fileType FileType) bool {
 	return me.Type == RecordData || me.Type == RecordEOF || ((me.Type == RecordExtSegment || me.Type == RecordStartSegment) && fileType == I16HEX) || ((me.Type == RecordExtLinear || me.Type == RecordStartLinear) && fileType == I32HEX)
 }
 
-// write writes this record's data to a writer in valid IHEX format.
-// Returns number of bytes written and any errors created during the writing process.
-func (me Record) write(w io.Writer) (int, error) {
+// appendTo appends this record in valid IHEX format, followed by ending, to dst.
+// digits is the lookup table of the 16 hexadecimal characters to encode bytes with, which selects uppercase or lowercase output.
+// Returns the extended slice. No allocations are made if dst has enough capacity for the encoded record.
+func (me Record) appendTo(dst []byte, digits string, ending string) []byte {
 
-	buf := bytes.NewBufferString(fmt.Sprintf("%c%02X%04X%02X", recordStartChar, len(me.Data), me.AddressOffset, me.Type))
+	dst = append(dst, recordStartChar)
+	dst = appendHexByte(dst, digits, byte(len(me.Data)))
+	dst = appendHexByte(dst, digits, byte(me.AddressOffset>>8))
+	dst = appendHexByte(dst, digits, byte(me.AddressOffset))
+	dst = appendHexByte(dst, digits, byte(me.Type))
 
-	hexBytes := make([]byte, len(me.Data)*2)
-	hex.Encode(hexBytes, me.Data)
+	for _, b := range me.Data {
+		dst = appendHexByte(dst, digits, b)
+	}
+
+	dst = appendHexByte(dst, digits, me.getChecksum())
+	return append(dst, ending...)
+}
 
-	if _, err := buf.WriteString(fmt.Sprintf("%s%02X\n", strings.ToUpper(string(hexBytes)), me.getChecksum())); err != nil {
-		return 0, err
+// appendHexByte appends the two hexadecimal characters of b, taken from the digits lookup table, to dst.
+func appendHexByte(dst []byte, digits string, b byte) []byte {
+	return append(dst, digits[b>>4], digits[b&0x0F])
+}
+
+// decodeHex decodes the hexadecimal characters in src and appends the decoded bytes to dst.
+// Returns the extended slice, or the position in src of the problem and an error if src has an odd length or contains a character that is not a hexadecimal digit.
+func decodeHex(dst []byte, src []byte) ([]byte, int, error) {
+
+	if len(src)%2 != 0 {
+		return dst, len(src), fmt.Errorf("odd number of hexadecimal characters (%d)", len(src))
+	}
+
+	for i := 0; i < len(src); i += 2 {
+		hi := recordHexValues[src[i]]
+		lo := recordHexValues[src[i+1]]
+
+		if hi == recordInvalidHexDigit {
+			return dst, i, fmt.Errorf("invalid hexadecimal character '%c' at position %d", src[i], i)
+		} else if lo == recordInvalidHexDigit {
+			return dst, i + 1, fmt.Errorf("invalid hexadecimal character '%c' at position %d", src[i+1], i+1)
+		}
+
+		dst = append(dst, hi<<4|lo)
 	}
 
-	return w.Write(buf.Bytes())
+	return dst, 0, nil
+}
+
+// parseHexNumber parses text as an unsigned hexadecimal number of at most maxDigits digits.
+// Returns the parsed number or an error if text is empty, too long or contains a character that is not a hexadecimal digit.
+func parseHexNumber(text []byte, maxDigits int) (uint64, error) {
+
+	if len(text) == 0 || len(text) > maxDigits {
+		return 0, fmt.Errorf("expected 1 to %d hexadecimal digits, found %d characters", maxDigits, len(text))
+	}
+
+	n := uint64(0)
+	for _, c := range text {
+		v := recordHexValues[c]
+		if v == recordInvalidHexDigit {
+			return 0, fmt.Errorf("invalid hexadecimal character '%c'", c)
+		}
+		n = n<<4 | uint64(v)
+	}
+	return n, nil
 }
 
 // getChecksum generates the 8 bit checksum for this record.
 // The IHEX specificaiton of the record checksum is that it is: "the two's complement of the least significant byte (LSB) of the sum of all decoded byte values in the record preceding the checksum".
+// The decoded byte values are the byte count, both address bytes, the record type and every data byte.
 // Returns the 1 byte (8 bit) checksum using the IHEX checksum specification.
 func (me Record) getChecksum() byte {
 
-	if me.Type == RecordEOF {
-		return recordEOFChecksum
-	}
-
-	sum := uint32(0)
+	sum := byte(len(me.Data)) + byte(me.AddressOffset>>8) + byte(me.AddressOffset) + byte(me.Type)
 
 	for _, d := range me.Data {
-		sum += uint32(d)
+		sum += d
 	}
 
-	checksum := byte(((^sum) & 0x000000FF)) + 1
-
-	return checksum
+	return ^sum + 1
 }
@@ -0,0 +1,270 @@
+package ihex
+
+import (
+	"fmt"
+	"iter"
+)
+
+// RecordFile is a HEX file of any file type.
+// It holds the records of the file in order and implements the behavior shared by all of the IHEX file formats.
+// The source position of each record is kept alongside it, for records that were read from a source.
+type RecordFile struct {
+	fileType  FileType
+	next      int
+	records   []Record
+	positions []Position
+}
+
+// GetType returns the file type of this HEX file, or 0 if this is a zero value RecordFile that was not created with NewRecordFile
+func (me *RecordFile) GetType() FileType {
+	return me.fileType
+}
+
+// ReadNext advances to the next record in this HEX file and returns it with a boolean flag of true.
+// If there are no more records in the file, a dummy record is returned along with the boolean flag of false.
+func (me *RecordFile) ReadNext() (Record, bool) {
+
+	if me.next >= len(me.records) {
+		return Record{}, false
+	}
+
+	me.next++
+	return me.records[me.next-1], true
+}
+
+// Reset resets this file back to the first record in the file to be ready to read again.
+func (me *RecordFile) Reset() {
+	me.next = 0
+}
+
+// Add adds a new record to the end of this HEX file
+// Returns an error if the record is incompatible with this file type
+func (me *RecordFile) Add(r Record) error {
+	return me.Insert(len(me.records), r)
+}
+
+// AddRecords adds a set of records to the end of this HEX file
+// Returns an error if any of the records are incompatible with this file type
+func (me *RecordFile) AddRecords(r ...Record) error {
+	for _, record := range r {
+		if err := me.Add(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of records in this HEX file
+func (me *RecordFile) Len() int {
+	return len(me.records)
+}
+
+// Records returns a copy of the list of records in this HEX file.
+// The records' data is shared with this file. Use Clone to get a fully independent copy.
+func (me *RecordFile) Records() []Record {
+	records := make([]Record, len(me.records))
+	copy(records, me.records)
+	return records
+}
+
+// All returns an iterator over the index and value of each record in this HEX file.
+// Unlike ReadNext, iterating does not affect the current read position of the file.
+func (me *RecordFile) All() iter.Seq2[int, Record] {
+	return func(yield func(int, Record) bool) {
+		for i, r := range me.records {
+			if !yield(i, r) {
+				return
+			}
+		}
+	}
+}
+
+// Insert inserts a record into this HEX file at the specified index, shifting the following records back by one.
+// An index equal to Len() adds the record to the end of the file.
+// The inserted record has no source position.
+// Returns an error if the index is out of range or the record is incompatible with this file type
+func (me *RecordFile) Insert(index int, r Record) error {
+	return me.insert(index, r, Position{})
+}
+
+// insert inserts a record read from the specified source position into this HEX file at the specified index.
+// Returns an error if the index is out of range or the record is incompatible with this file type
+func (me *RecordFile) insert(index int, r Record, pos Position) error {
+
+	if index < 0 || index > len(me.records) {
+		return fmt.Errorf("Record index %d out of range. File contains %d records", index, len(me.records))
+	}
+
+	// a zero value RecordFile has no file type to check records against
+	if !me.fileType.isValid() {
+		return fmt.Errorf("HEX file has no file type. Create it with NewRecordFile")
+	}
+
+	if !r.validate(me.fileType) {
+		return &InvalidRecordTypeError{
+			InvaildFileType:   me.fileType,
+			InvalidRecordType: r.Type,
+		}
+	}
+
+	me.records = append(me.records, Record{})
+	copy(me.records[index+1:], me.records[index:])
+	me.records[index] = r
+
+	me.positions = append(me.positions, Position{})
+	copy(me.positions[index+1:], me.positions[index:])
+	me.positions[index] = pos
+
+	// keep ReadNext pointed at the same record it would have returned next
+	if index < me.next {
+		me.next++
+	}
+	return nil
+}
+
+// Remove removes the record at the specified index from this HEX file, shifting the following records forward by one.
+// Returns an error if the index is out of range
+func (me *RecordFile) Remove(index int) error {
+
+	if index < 0 || index >= len(me.records) {
+		return fmt.Errorf("Record index %d out of range. File contains %d records", index, len(me.records))
+	}
+
+	me.records = append(me.records[:index], me.records[index+1:]...)
+	me.positions = append(me.positions[:index], me.positions[index+1:]...)
+
+	// keep ReadNext pointed at the same record it would have returned next
+	if index < me.next {
+		me.next--
+	}
+	return nil
+}
+
+// Position returns the position in the source of the record at the specified index.
+// Returns false if the index is out of range or the record was not read from a source.
+func (me *RecordFile) Position(index int) (Position, bool) {
+
+	if index < 0 || index >= len(me.positions) {
+		return Position{}, false
+	}
+
+	pos := me.positions[index]
+	return pos, pos.IsValid()
+}
+
+// FindAddress searches this HEX file for the data record containing the specified absolute address.
+// Extended address records are applied in order to resolve the absolute address of each data record.
+// Returns the index of the data record and true, or false if no data record contains the address.
+func (me *RecordFile) FindAddress(address uint32) (int, bool) {
+
+	addresses := addressState{}
+
+	for i, r := range me.records {
+		start, err := addresses.next(r)
+
+		if err == nil && r.Type == RecordData && address >= start && uint64(address) < uint64(start)+uint64(len(r.Data)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Clone returns a deep copy of this HEX file, including copies of all record data.
+// The read position of the copy is reset to the first record.
+func (me *RecordFile) Clone() File {
+	c := me.clone()
+	return &c
+}
+
+// clone returns a deep copy of this HEX file with its read position reset
+func (me *RecordFile) clone() RecordFile {
+
+	c := RecordFile{
+		fileType:  me.fileType,
+		records:   make([]Record, len(me.records)),
+		positions: make([]Position, len(me.positions)),
+	}
+
+	copy(c.positions, me.positions)
+
+	for i, r := range me.records {
+		r.Data = append(make([]byte, 0, len(r.Data)), r.Data...)
+		c.records[i] = r
+	}
+	return c
+}
+
+// NewRecordFile creates and initializes a new, empty HEX file of the specified file type
+// Returns the newly created HEX file or an error if the file type is not I8HEX, I16HEX or I32HEX
+func NewRecordFile(fileType FileType) (*RecordFile, error) {
+
+	if !fileType.isValid() {
+		return nil, fmt.Errorf("Unsupported HEX file type: %d. Supported file types are I8HEX, I16HEX and I32HEX", int(fileType))
+	}
+	return newRecordFile(fileType), nil
+}
+
+// newRecordFile creates and initializes a new, empty HEX file of a file type known to be valid
+func newRecordFile(fileType FileType) *RecordFile {
+	return &RecordFile{
+		fileType:  fileType,
+		records:   make([]Record, 0),
+		positions: make([]Position, 0),
+	}
+}
+
+// fileTypeParam is implemented by the types that fix the file type of a typedFile: i8hexType, i16hexType and i32hexType
+type fileTypeParam interface {
+	fileType() FileType
+}
+
+// typedFile is a RecordFile whose file type is fixed by its type parameter rather than set by a constructor,
+// so that its zero value is an empty file of that type, ready to use. I8HEXFile, I16HEXFile and I32HEXFile are instances of it.
+type typedFile[T fileTypeParam] struct {
+	RecordFile
+}
+
+// GetType returns the file type of this HEX file
+func (me *typedFile[T]) GetType() FileType {
+	var t T
+	return t.fileType()
+}
+
+// Add adds a new record to the end of this HEX file
+// Returns an error if the record is incompatible with this file type
+func (me *typedFile[T]) Add(r Record) error {
+	return me.typed().Add(r)
+}
+
+// AddRecords adds a set of records to the end of this HEX file
+// Returns an error if any of the records are incompatible with this file type
+func (me *typedFile[T]) AddRecords(r ...Record) error {
+	return me.typed().AddRecords(r...)
+}
+
+// Insert inserts a record into this HEX file at the specified index, shifting the following records back by one.
+// Returns an error if the index is out of range or the record is incompatible with this file type
+func (me *typedFile[T]) Insert(index int, r Record) error {
+	return me.typed().Insert(index, r)
+}
+
+// Clone returns a deep copy of this HEX file, including copies of all record data.
+// The read position of the copy is reset to the first record.
+func (me *typedFile[T]) Clone() File {
+	return &typedFile[T]{
+		RecordFile: me.typed().clone(),
+	}
+}
+
+// typed returns the RecordFile of this HEX file with its file type set, which a zero value typedFile does not have yet
+func (me *typedFile[T]) typed() *RecordFile {
+	me.fileType = me.GetType()
+	return &me.RecordFile
+}
+
+// newTypedFile creates and initializes a new, empty HEX file of the file type fixed by T
+func newTypedFile[T fileTypeParam]() *typedFile[T] {
+	f := &typedFile[T]{}
+	f.RecordFile = *newRecordFile(f.GetType())
+	return f
+}
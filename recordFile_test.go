@@ -0,0 +1,70 @@
+package ihex
+
+import "testing"
+
+func TestZeroValueFileTypes(t *testing.T) {
+
+	ext := Record{Type: RecordExtLinear, Data: []byte{0x00, 0x01}}
+
+	var f32 I32HEXFile
+	if f32.GetType() != I32HEX {
+		t.Fatalf("zero value I32HEXFile has type %d", f32.GetType())
+	}
+
+	if err := f32.Add(ext); err != nil {
+		t.Fatalf("zero value I32HEXFile rejected an extended linear address record: %v", err)
+	}
+
+	var f16 I16HEXFile
+	if err := f16.Add(ext); err == nil {
+		t.Fatal("zero value I16HEXFile accepted an extended linear address record")
+	}
+
+	var f8 I8HEXFile
+	if f8.GetType() != I8HEX {
+		t.Fatalf("zero value I8HEXFile has type %d", f8.GetType())
+	}
+
+	var f8b I8HEXFile
+	a := Record{Type: RecordData, AddressOffset: 0, Data: []byte{1}}
+	b := Record{Type: RecordData, AddressOffset: 1, Data: []byte{2}}
+
+	if err := f8b.AddRecords(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []Record{a, b} {
+		if r, ok := f8b.ReadNext(); !ok || r.AddressOffset != want.AddressOffset {
+			t.Fatalf("ReadNext %d of a zero value I8HEXFile returned %v, %t", i, r, ok)
+		}
+	}
+
+	if _, ok := f8b.ReadNext(); ok {
+		t.Fatal("ReadNext returned a record past the end of the file")
+	}
+
+	if c, ok := f8b.Clone().(*I8HEXFile); !ok || c.Len() != 2 || c.GetType() != I8HEX {
+		t.Fatalf("Clone of an I8HEXFile returned %T", f8b.Clone())
+	}
+
+	var rf RecordFile
+	if err := rf.Add(Record{Type: RecordData, Data: []byte{1}}); err == nil {
+		t.Fatal("zero value RecordFile accepted a record without a file type")
+	}
+}
+
+func TestNewRecordFileRejectsUnknownTypes(t *testing.T) {
+
+	for _, ft := range []FileType{0, 7, 24, 64} {
+		if _, err := NewRecordFile(ft); err == nil {
+			t.Errorf("NewRecordFile accepted file type %d", ft)
+		}
+	}
+
+	for _, ft := range []FileType{I8HEX, I16HEX, I32HEX} {
+		f, err := NewRecordFile(ft)
+		if err != nil || f.GetType() != ft {
+			t.Errorf("NewRecordFile(%d) = %v, %v", ft, f, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ihex
+
+import "io"
+
+// RecordReader reads HEX records one at a time from an underlying reader.
+// Records are decoded into a reusable buffer, so reading a record does not allocate.
+type RecordReader struct {
+	scanner *lineScanner
+	buffer  []byte
+	index   int
+	limits  parseLimits
+}
+
+// Read reads the next record from the underlying reader.
+// The returned record's Data is only valid until the next call to Read. Callers that keep records must copy the data.
+// Returns the record read, io.EOF if there are no more records, or an IndexedRecordError if the record is formatted incorrectly or crosses a limit.
+func (me *RecordReader) Read() (Record, error) {
+
+	if !me.scanner.Scan() {
+		if err := me.scanner.lineErr(me.index); err != nil {
+			return Record{}, err
+		}
+		return Record{}, io.EOF
+	}
+
+	// the scanner still returns the last partial line after a read error, which must not be parsed as a truncated record
+	if err := me.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+
+	r, buf, err := parseRecord(me.scanner.Bytes(), me.buffer)
+	me.buffer = buf
+
+	if err == nil {
+		err = setRecordErrorText(me.limits.add(r), me.scanner.Bytes())
+	}
+
+	if err != nil {
+		return r, &IndexedRecordError{
+			Index:       me.index,
+			RecordError: setRecordErrorLine(err, me.index+1),
+		}
+	}
+
+	me.index++
+	return r, nil
+}
+
+// Position returns the position in the source of the record most recently returned by Read
+func (me *RecordReader) Position() Position {
+	return Position{
+		Line:   me.index,
+		Offset: me.scanner.Offset(),
+	}
+}
+
+// NewRecordReader is equivalent to calling NewRecordReaderOptions(r, ParseOptions{})
+func NewRecordReader(r io.Reader) *RecordReader {
+	return NewRecordReaderOptions(r, ParseOptions{})
+}
+
+// NewRecordReaderOptions creates and initializes a new RecordReader that reads records from r.
+// Input compressed with gzip or bzip2 is detected from its magic number and decompressed as it is read, so it is never held in memory all at once.
+// The resource limits of opts are enforced as records are read. The other options are ignored.
+// Returns the newly created RecordReader
+func NewRecordReaderOptions(r io.Reader, opts ParseOptions) *RecordReader {
+	return newRecordReader(newCountingReader(newDecompressReader(r), opts), opts)
+}
+
+// newRecordReader creates a RecordReader that reads records from a countingReader that already enforces the MaxInputBytes limit of opts
+func newRecordReader(cr *countingReader, opts ParseOptions) *RecordReader {
+
+	return &RecordReader{
+		scanner: newLineScanner(cr),
+		buffer:  make([]byte, 0, recordMaximumDataSize+recordHeaderAndChecksumSize),
+		index:   0,
+		limits:  parseLimits{opts: opts},
+	}
+}
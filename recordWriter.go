@@ -0,0 +1,62 @@
+package ihex
+
+import (
+	"bufio"
+	"io"
+)
+
+// RecordWriter writes HEX records one at a time to an underlying writer.
+// Records are encoded into a reusable buffer and passed through a buffered writer, so writing a record does not allocate.
+// Flush must be called after the last record to make sure all records reach the underlying writer.
+type RecordWriter struct {
+	writer *bufio.Writer
+	buffer []byte
+	digits string
+	ending string
+}
+
+// WriteRecord encodes a record in HEX format and writes it to the buffered writer.
+// Returns the number of bytes written or any errors encountered during writing.
+func (me *RecordWriter) WriteRecord(r Record) (int, error) {
+	me.buffer = r.appendTo(me.buffer[:0], me.digits, me.ending)
+	return me.writer.Write(me.buffer)
+}
+
+// Flush writes any buffered records to the underlying writer.
+// Returns any errors generated by the underlying writer.
+func (me *RecordWriter) Flush() error {
+	return me.writer.Flush()
+}
+
+// NewRecordWriter is equivalent to calling NewRecordWriterOptions(w, WriteOptions{})
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return NewRecordWriterOptions(w, WriteOptions{})
+}
+
+// NewRecordWriterOptions creates and initializes a new RecordWriter that writes records to w.
+// Records are encoded using the line ending, hexadecimal case and buffer size of opts. The other options are ignored.
+// Returns the newly created RecordWriter
+func NewRecordWriterOptions(w io.Writer, opts WriteOptions) *RecordWriter {
+
+	digits := recordHexDigits
+	if opts.Lowercase {
+		digits = recordLowerHexDigits
+	}
+
+	ending := opts.LineEnding
+	if ending == "" {
+		ending = recordLineEnding
+	}
+
+	size := opts.BufferSize
+	if size <= 0 {
+		size = writerDefaultBufferSize
+	}
+
+	return &RecordWriter{
+		writer: bufio.NewWriterSize(w, size),
+		buffer: make([]byte, 0, recordMaximumSizeChars+len(ending)),
+		digits: digits,
+		ending: ending,
+	}
+}
@@ -0,0 +1,403 @@
+package ihex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const (
+	// tekStartChar is the character that starts a Tektronix Hex record
+	tekStartChar = '/'
+
+	// tekHeaderSize is the number of characters in a Tektronix Hex record before its data: start character, address, byte count and header checksum
+	tekHeaderSize = 1 + 4 + 2 + 2
+
+	// tekBytesPerRecord is the number of data bytes written in each Tektronix Hex record
+	tekBytesPerRecord = 16
+
+	// tekAddressSpace is the number of bytes addressable by the 16 bit addresses of Tektronix Hex records
+	tekAddressSpace = 0x10000
+
+	// xtekStartChar is the character that starts an Extended Tektronix Hex record
+	xtekStartChar = '%'
+
+	// The Extended Tektronix Hex record types
+	xtekTypeData        = '6'
+	xtekTypeSymbol      = '3'
+	xtekTypeTermination = '8'
+
+	// xtekHeaderSize is the number of characters in an Extended Tektronix Hex record before its address: start character, length, type and checksum
+	xtekHeaderSize = 1 + 2 + 1 + 2
+
+	// xtekBytesPerRecord is the number of data bytes written in each Extended Tektronix Hex record
+	xtekBytesPerRecord = 16
+)
+
+// xtekCharValues is the value of each character for the purposes of the Extended Tektronix Hex checksum.
+// Characters that can not appear in a record map to 0xFF.
+var xtekCharValues [256]byte
+
+func init() {
+	for i := range xtekCharValues {
+		xtekCharValues[i] = 0xFF
+	}
+	for i := 0; i < 10; i++ {
+		xtekCharValues['0'+i] = byte(i)
+	}
+	for i := 0; i < 26; i++ {
+		xtekCharValues['A'+i] = byte(10 + i)
+		xtekCharValues['a'+i] = byte(40 + i)
+	}
+	xtekCharValues['$'] = 36
+	xtekCharValues['%'] = 37
+	xtekCharValues['.'] = 38
+	xtekCharValues['_'] = 39
+}
+
+// ReadTektronix reads a Tektronix Hex file and creates an image of its contents.
+// Each record is "/AAAANNHHDD...DDCC": a 16 bit address, a byte count, a checksum of the address and byte count, the data and a checksum of the data.
+// Both checksums are the sum of the values of the hexadecimal digits they cover, modulo 256.
+// A record with a byte count of 0 ends the file, and its address is the start address of the image.
+// Returns the new image or an InvalidRecordError pointing at the first malformed record.
+func ReadTektronix(r io.Reader) (*Image, error) {
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	scanner := bufio.NewScanner(r)
+	data := make([]byte, 0, recordMaximumDataSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+
+		if len(text) == 0 {
+			continue
+		}
+
+		if text[0] != tekStartChar {
+			return nil, newLineError(ErrBadStartCode, line, 1, text, "Tektronix Hex record must begin with '%c'. Record starts with: '%c'", tekStartChar, text[0])
+		}
+
+		if len(text) < tekHeaderSize {
+			return nil, newLineError(ErrLengthMismatch, line, len(text)+1, text, "Tektronix Hex record must be at least %d characters long", tekHeaderSize)
+		}
+
+		if pos, ok := findBadHexDigit(text[1:]); ok {
+			return nil, newLineError(ErrBadHexDigit, line, pos+2, text, "Invalid hexadecimal character '%c'", text[pos+1])
+		}
+
+		address, _ := parseHexNumber(text[1:5], 4)
+		count, _ := parseHexNumber(text[5:7], 2)
+		headerChecksum, _ := parseHexNumber(text[7:9], 2)
+
+		if sum := nibbleSum(text[1:7]); sum != byte(headerChecksum) {
+			return nil, newLineError(ErrChecksumMismatch, line, 8, text, "Header checksum '%02X' does not match computed checksum '%02X'", headerChecksum, sum)
+		}
+
+		if count == 0 {
+			img.Start = uint32(address)
+			img.HasStart = true
+			return img, nil
+		}
+
+		if expected := tekHeaderSize + int(count)*2 + 2; len(text) != expected {
+			return nil, newLineError(ErrLengthMismatch, line, 6, text, "Record byte count (%d) requires a record of %d characters. Record length: %d", count, expected, len(text))
+		}
+
+		dataText := text[tekHeaderSize : len(text)-2]
+		dataChecksum, _ := parseHexNumber(text[len(text)-2:], 2)
+
+		if sum := nibbleSum(dataText); sum != byte(dataChecksum) {
+			return nil, newLineError(ErrChecksumMismatch, line, len(text)-1, text, "Data checksum '%02X' does not match computed checksum '%02X'", dataChecksum, sum)
+		}
+
+		if address+count > tekAddressSpace {
+			return nil, newLineError(ErrStructure, line, 2, text, "Record data extends past the end of the 16 bit address space")
+		}
+
+		data, _, _ = decodeHex(data[:0], dataText)
+		img.Set(uint32(address), data)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, newLineError(ErrStructure, line+1, 0, nil, "Tektronix Hex file must end with a record with a byte count of 0")
+}
+
+// WriteTektronix writes the data of an image to the provided writer in Tektronix Hex format.
+// Data is written in records of 16 bytes, followed by a final record holding the start address of the image (or 0 if it has none).
+// Returns an error if the image does not fit in the 16 bit address space of Tektronix Hex files or any errors generated by the provided writer.
+func WriteTektronix(w io.Writer, img *Image) error {
+
+	if n := len(img.Segments); n > 0 && img.Segments[n-1].End() > tekAddressSpace {
+		return fmt.Errorf("Image data at address %08X does not fit in the 16 bit address space of Tektronix Hex files", img.Segments[n-1].End()-1)
+	}
+
+	if img.HasStart && img.Start >= tekAddressSpace {
+		return fmt.Errorf("Start address %08X does not fit in the 16 bit address space of Tektronix Hex files", img.Start)
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, tekHeaderSize+tekBytesPerRecord*2+3)
+
+	for _, seg := range img.Segments {
+		for i := 0; i < len(seg.Data); i += tekBytesPerRecord {
+			end := i + tekBytesPerRecord
+			if end > len(seg.Data) {
+				end = len(seg.Data)
+			}
+
+			buf = appendTektronixRecord(buf[:0], uint16(seg.Address+uint32(i)), seg.Data[i:end])
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	start := uint16(0)
+	if img.HasStart {
+		start = uint16(img.Start)
+	}
+
+	if _, err := bw.Write(appendTektronixRecord(buf[:0], start, nil)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// appendTektronixRecord appends a Tektronix Hex record, including its line ending, to dst.
+// Records without data only contain the header, as used by the final record of a file.
+// Returns the extended slice.
+func appendTektronixRecord(dst []byte, address uint16, data []byte) []byte {
+
+	dst = append(dst, tekStartChar)
+	start := len(dst)
+	dst = appendHexByte(dst, recordHexDigits, byte(address>>8))
+	dst = appendHexByte(dst, recordHexDigits, byte(address))
+	dst = appendHexByte(dst, recordHexDigits, byte(len(data)))
+	dst = appendHexByte(dst, recordHexDigits, nibbleSum(dst[start:]))
+
+	if len(data) > 0 {
+		start = len(dst)
+		for _, b := range data {
+			dst = appendHexByte(dst, recordHexDigits, b)
+		}
+		dst = appendHexByte(dst, recordHexDigits, nibbleSum(dst[start:]))
+	}
+
+	return append(dst, recordLineEnding...)
+}
+
+// ReadExtendedTektronix reads an Extended Tektronix Hex file and creates an image of its contents.
+// Each record is "%LLTCCNA...AD...D": the record length, the record type, a checksum, the number of address digits, the address and the data.
+// The checksum is the sum of the values of every character of the record other than the '%' and the checksum itself, modulo 256.
+// Data records (type 6) are added to the image, symbol records (type 3) are skipped, and a termination record (type 8) ends the file and holds the start address of the image.
+// Returns the new image or an InvalidRecordError pointing at the first malformed record.
+func ReadExtendedTektronix(r io.Reader) (*Image, error) {
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	scanner := bufio.NewScanner(r)
+	data := make([]byte, 0, recordMaximumDataSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+
+		if len(text) == 0 {
+			continue
+		}
+
+		if text[0] != xtekStartChar {
+			return nil, newLineError(ErrBadStartCode, line, 1, text, "Extended Tektronix Hex record must begin with '%c'. Record starts with: '%c'", xtekStartChar, text[0])
+		}
+
+		if len(text) < xtekHeaderSize {
+			return nil, newLineError(ErrLengthMismatch, line, len(text)+1, text, "Extended Tektronix Hex record must be at least %d characters long", xtekHeaderSize)
+		}
+
+		length, err := parseHexNumber(text[1:3], 2)
+		if err != nil {
+			return nil, newLineError(ErrBadHexDigit, line, 2, text, "Invalid record length: %s", err.Error())
+		}
+
+		if int(length) != len(text)-1 {
+			return nil, newLineError(ErrLengthMismatch, line, 2, text, "Record length (%d) does not match actual record length (%d)", length, len(text)-1)
+		}
+
+		checksum, err := parseHexNumber(text[4:6], 2)
+		if err != nil {
+			return nil, newLineError(ErrBadHexDigit, line, 5, text, "Invalid record checksum: %s", err.Error())
+		}
+
+		sum := byte(0)
+		for i, c := range text[1:] {
+			if i == 3 || i == 4 {
+				continue
+			}
+			v := xtekCharValues[c]
+			if v == 0xFF {
+				return nil, newLineError(ErrBadHexDigit, line, i+2, text, "Invalid character '%c' in Extended Tektronix Hex record", c)
+			}
+			sum += v
+		}
+
+		if sum != byte(checksum) {
+			return nil, newLineError(ErrChecksumMismatch, line, 5, text, "Record checksum '%02X' does not match computed checksum '%02X'", checksum, sum)
+		}
+
+		recordType := text[3]
+		if recordType == xtekTypeSymbol {
+			continue
+		} else if recordType != xtekTypeData && recordType != xtekTypeTermination {
+			return nil, newLineError(ErrInvalidRecordType, line, 4, text, "Unknown Extended Tektronix Hex record type '%c'", recordType)
+		}
+
+		if len(text) < xtekHeaderSize+1 {
+			return nil, newLineError(ErrLengthMismatch, line, len(text)+1, text, "Record is missing its address")
+		}
+
+		digits, err := parseHexNumber(text[xtekHeaderSize:xtekHeaderSize+1], 1)
+		if err != nil {
+			return nil, newLineError(ErrBadHexDigit, line, xtekHeaderSize+1, text, "Invalid address length: %s", err.Error())
+		}
+		if digits == 0 {
+			digits = 16
+		}
+
+		addressEnd := xtekHeaderSize + 1 + int(digits)
+		if len(text) < addressEnd {
+			return nil, newLineError(ErrLengthMismatch, line, len(text)+1, text, "Record is shorter than its %d digit address", digits)
+		}
+
+		address, err := parseHexNumber(text[xtekHeaderSize+1:addressEnd], 16)
+		if err != nil {
+			return nil, newLineError(ErrBadHexDigit, line, xtekHeaderSize+2, text, "Invalid address: %s", err.Error())
+		}
+
+		if recordType == xtekTypeTermination {
+			if address >= 1<<32 {
+				return nil, newLineError(ErrStructure, line, xtekHeaderSize+2, text, "Start address does not fit in 32 bits")
+			}
+
+			img.Start = uint32(address)
+			img.HasStart = true
+			return img, nil
+		}
+
+		var pos int
+		data, pos, err = decodeHex(data[:0], text[addressEnd:])
+		if err != nil {
+			return nil, newLineError(ErrBadHexDigit, line, addressEnd+pos+1, text, "Unable to decode hexadecimal record contents: %s", err.Error())
+		}
+
+		// the address is checked on its own first, since adding the data length to a 16 digit address can overflow
+		if address >= 1<<32 || address+uint64(len(data)) > 1<<32 {
+			return nil, newLineError(ErrStructure, line, xtekHeaderSize+2, text, "Record data extends past the end of the 32 bit address space")
+		}
+
+		img.Set(uint32(address), data)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, newLineError(ErrStructure, line+1, 0, nil, "Extended Tektronix Hex file must end with a termination record")
+}
+
+// WriteExtendedTektronix writes the data of an image to the provided writer in Extended Tektronix Hex format.
+// Data is written in data records of 16 bytes with 8 digit addresses, followed by a termination record holding the start address of the image (or 0 if it has none).
+// Returns any errors generated by the provided writer during the writing process.
+func WriteExtendedTektronix(w io.Writer, img *Image) error {
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, xtekHeaderSize+9+xtekBytesPerRecord*2+1)
+
+	for _, seg := range img.Segments {
+		for i := 0; i < len(seg.Data); i += xtekBytesPerRecord {
+			end := i + xtekBytesPerRecord
+			if end > len(seg.Data) {
+				end = len(seg.Data)
+			}
+
+			buf = appendExtendedTektronixRecord(buf[:0], xtekTypeData, seg.Address+uint32(i), seg.Data[i:end])
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	start := uint32(0)
+	if img.HasStart {
+		start = img.Start
+	}
+
+	if _, err := bw.Write(appendExtendedTektronixRecord(buf[:0], xtekTypeTermination, start, nil)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// appendExtendedTektronixRecord appends an Extended Tektronix Hex record of the specified type with an 8 digit address, including its line ending, to dst.
+// Returns the extended slice.
+func appendExtendedTektronixRecord(dst []byte, recordType byte, address uint32, data []byte) []byte {
+
+	start := len(dst)
+	length := xtekHeaderSize - 1 + 9 + len(data)*2
+
+	dst = append(dst, xtekStartChar)
+	dst = appendHexByte(dst, recordHexDigits, byte(length))
+	dst = append(dst, recordType, '0', '0', '8')
+	dst = appendHexByte(dst, recordHexDigits, byte(address>>24))
+	dst = appendHexByte(dst, recordHexDigits, byte(address>>16))
+	dst = appendHexByte(dst, recordHexDigits, byte(address>>8))
+	dst = appendHexByte(dst, recordHexDigits, byte(address))
+
+	for _, b := range data {
+		dst = appendHexByte(dst, recordHexDigits, b)
+	}
+
+	sum := byte(0)
+	for _, c := range dst[start+1:] {
+		sum += xtekCharValues[c]
+	}
+
+	// the checksum placeholder digits were counted as 0, so they do not affect the sum
+	checksum := appendHexByte(nil, recordHexDigits, sum)
+	dst[start+4] = checksum[0]
+	dst[start+5] = checksum[1]
+
+	return append(dst, recordLineEnding...)
+}
+
+// nibbleSum returns the sum of the values of the hexadecimal digits in text, modulo 256.
+// text must only contain hexadecimal digits.
+func nibbleSum(text []byte) byte {
+
+	sum := byte(0)
+	for _, c := range text {
+		sum += recordHexValues[c]
+	}
+	return sum
+}
+
+// findBadHexDigit returns the position of the first character in text that is not a hexadecimal digit and true, or false if there is none
+func findBadHexDigit(text []byte) (int, bool) {
+
+	for i, c := range text {
+		if recordHexValues[c] == recordInvalidHexDigit {
+			return i, true
+		}
+	}
+	return 0, false
+}
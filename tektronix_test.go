@@ -0,0 +1,137 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testImage returns an image with two segments and a start address, for round trip tests of the image based formats
+func testImage() *Image {
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	img.Set(0x0100, testData(40))
+	img.Set(0x2000, []byte{0xDE, 0xAD, 0xBE, 0xEF})
+	img.Start = 0x0100
+	img.HasStart = true
+	return img
+}
+
+// checkImage fails the test if two images do not hold the same segments and start address
+func checkImage(t *testing.T, got *Image, want *Image) {
+
+	t.Helper()
+
+	if got.HasStart != want.HasStart || got.Start != want.Start || len(got.Segments) != len(want.Segments) {
+		t.Fatalf("image has %d segments and start %08X (%t), expected %d segments and start %08X (%t)", len(got.Segments), got.Start, got.HasStart, len(want.Segments), want.Start, want.HasStart)
+	}
+
+	for i := range want.Segments {
+		if got.Segments[i].Address != want.Segments[i].Address || !bytes.Equal(got.Segments[i].Data, want.Segments[i].Data) {
+			t.Fatalf("segment %d at %08X does not match the expected segment at %08X", i, got.Segments[i].Address, want.Segments[i].Address)
+		}
+	}
+}
+
+func TestTektronixRoundTrip(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteTektronix(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := ReadTektronix(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, img, testImage())
+}
+
+func TestTektronixRecords(t *testing.T) {
+
+	img := &Image{Segments: []Segment{{Address: 0x0010, Data: []byte{0x01, 0x02}}}}
+
+	var buf bytes.Buffer
+	if err := WriteTektronix(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// both checksums are the sum of the digits they cover: 0+0+1+0+0+2 and 0+1+0+2
+	if want := "/00100203010203\n/00000000\n"; buf.String() != want {
+		t.Fatalf("wrote %q, expected %q", buf.String(), want)
+	}
+
+	for _, c := range []struct {
+		src  string
+		kind error
+	}{
+		{"/00100204010203\n/00000000\n", ErrChecksumMismatch},
+		{"/00100203010204\n/00000000\n", ErrChecksumMismatch},
+		{"/001002030102\n/00000000\n", ErrLengthMismatch},
+		{":00100203010203\n/00000000\n", ErrBadStartCode},
+		{"/0010020301G203\n/00000000\n", ErrBadHexDigit},
+		{"/FFFF023E010203\n/00000000\n", ErrStructure},
+		{"/00100203010203\n", ErrStructure},
+	} {
+		if _, err := ReadTektronix(strings.NewReader(c.src)); !errors.Is(err, c.kind) {
+			t.Errorf("%q: expected %v, got %v", c.src, c.kind, err)
+		}
+	}
+
+	high := &Image{Segments: []Segment{{Address: 0xFFFF, Data: []byte{1, 2}}}}
+	if err := WriteTektronix(&buf, high); err == nil {
+		t.Fatal("data past the 16 bit address space was written")
+	}
+}
+
+func TestExtendedTektronixRoundTrip(t *testing.T) {
+
+	want := testImage()
+	want.Set(0xFFFFFFF0, testData(16))
+
+	var buf bytes.Buffer
+	if err := WriteExtendedTektronix(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := ReadExtendedTektronix(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, img, want)
+}
+
+func TestExtendedTektronixRecords(t *testing.T) {
+
+	img := &Image{Segments: []Segment{{Address: 0x0010, Data: []byte{0xAB}}}}
+
+	var buf bytes.Buffer
+	if err := WriteExtendedTektronix(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// the checksum is the sum of the values of the other characters: 1+0+6+8+1+10+11 and 0+14+8+8
+	if want := "%10625800000010AB\n%0E81E800000000\n"; buf.String() != want {
+		t.Fatalf("wrote %q, expected %q", buf.String(), want)
+	}
+
+	for _, c := range []struct {
+		src  string
+		kind error
+	}{
+		{"%10626800000010AB\n%0E81E800000000\n", ErrChecksumMismatch},
+		{"%10625800000010AC\n%0E81E800000000\n", ErrChecksumMismatch},
+		{"%11625800000010AB\n%0E81E800000000\n", ErrLengthMismatch},
+		{"%10726800000010AB\n%0E81E800000000\n", ErrInvalidRecordType},
+		{"%1A62B0FFFFFFFFFFFFFFFFAABB\n%0E81E800000000\n", ErrStructure},
+		{"%10625800000010AB\n", ErrStructure},
+	} {
+		if _, err := ReadExtendedTektronix(strings.NewReader(c.src)); !errors.Is(err, c.kind) {
+			t.Errorf("%q: expected %v, got %v", c.src, c.kind, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package ihex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// titxtAddressChar is the character that starts a TI-TXT address line
+	titxtAddressChar = '@'
+
+	// titxtBytesPerLine is the number of data bytes written on each TI-TXT data line
+	titxtBytesPerLine = 16
+)
+
+// ReadTITXT reads a TI-TXT file and creates an image of its contents.
+// TI-TXT files are made of "@ADDR" lines that set the address of the data that follows them,
+// data lines of space separated hexadecimal bytes, and a final "q" line that ends the file.
+// Returns the new image or an InvalidRecordError pointing at the first malformed line.
+func ReadTITXT(r io.Reader) (*Image, error) {
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	address := uint64(0)
+	hasAddress := false
+	data := make([]byte, 0, titxtBytesPerLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		trimmed := bytes.TrimSpace(text)
+
+		if len(trimmed) == 0 {
+			continue
+		}
+
+		column := bytes.Index(text, trimmed) + 1
+
+		if trimmed[0] == 'q' || trimmed[0] == 'Q' {
+			return img, nil
+		}
+
+		if trimmed[0] == titxtAddressChar {
+			a, err := parseHexNumber(trimmed[1:], 8)
+			if err != nil {
+				return nil, newLineError(ErrBadHexDigit, line, column+1, text, "Invalid TI-TXT address: %s", err.Error())
+			}
+
+			address = a
+			hasAddress = true
+			continue
+		}
+
+		if !hasAddress {
+			return nil, newLineError(ErrStructure, line, column, text, "TI-TXT data must follow an '%c' address line", titxtAddressChar)
+		}
+
+		data = data[:0]
+		for i := 0; i < len(text); {
+			if text[i] == ' ' || text[i] == '\t' {
+				i++
+				continue
+			}
+
+			start := i
+			for i < len(text) && text[i] != ' ' && text[i] != '\t' {
+				i++
+			}
+
+			b, err := parseHexNumber(text[start:i], 2)
+			if err != nil || i-start != 2 {
+				return nil, newLineError(ErrBadHexDigit, line, start+1, text, "TI-TXT data must be space separated pairs of hexadecimal digits. Found: '%s'", text[start:i])
+			}
+			data = append(data, byte(b))
+		}
+
+		if address+uint64(len(data)) > 1<<32 {
+			return nil, newLineError(ErrStructure, line, column, text, "TI-TXT data extends past the end of the 32 bit address space")
+		}
+
+		img.Set(uint32(address), data)
+		address += uint64(len(data))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, newLineError(ErrStructure, line+1, 0, nil, "TI-TXT file must end with a 'q' line")
+}
+
+// WriteTITXT writes the data of an image to the provided writer in TI-TXT format.
+// Each segment of the image is written as an "@ADDR" line followed by data lines of 16 bytes, and the file is ended with a "q" line.
+// The start address of the image is not written, since TI-TXT files have no way to store it.
+// Returns any errors generated by the provided writer during the writing process.
+func WriteTITXT(w io.Writer, img *Image) error {
+
+	bw := bufio.NewWriter(w)
+	line := make([]byte, 0, titxtBytesPerLine*3+1)
+
+	for _, seg := range img.Segments {
+		if _, err := fmt.Fprintf(bw, "%c%04X\n", titxtAddressChar, seg.Address); err != nil {
+			return err
+		}
+
+		for i := 0; i < len(seg.Data); i += titxtBytesPerLine {
+			end := i + titxtBytesPerLine
+			if end > len(seg.Data) {
+				end = len(seg.Data)
+			}
+
+			line = line[:0]
+			for j, b := range seg.Data[i:end] {
+				if j > 0 {
+					line = append(line, ' ')
+				}
+				line = appendHexByte(line, recordHexDigits, b)
+			}
+			line = append(line, '\n')
+
+			if _, err := bw.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := bw.WriteString("q\n"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,80 @@
+package ihex
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriteTITXT(t *testing.T) {
+
+	img := &Image{Segments: make([]Segment, 0)}
+	img.Set(0xC000, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17})
+	img.Set(0x10000, []byte{0xAB})
+
+	var buf bytes.Buffer
+	if err := WriteTITXT(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "@C000\n" +
+		"00 01 02 03 04 05 06 07 08 09 0A 0B 0C 0D 0E 0F\n" +
+		"10 11\n" +
+		"@10000\n" +
+		"AB\n" +
+		"q\n"
+
+	if buf.String() != want {
+		t.Fatalf("wrote:\n%s\nexpected:\n%s", buf.String(), want)
+	}
+
+	read, err := ReadTITXT(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, read, img)
+}
+
+func TestReadTITXT(t *testing.T) {
+
+	// lowercase digits, CRLF line endings, blank lines, indentation and data lines of any length are accepted
+	src := "@f000\r\n\r\n  01 02 03\r\n04\r\n@F010\r\nff\tFE\r\nq\r\nthis line is never read\r\n"
+
+	img, err := ReadTITXT(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImage(t, img, &Image{Segments: []Segment{
+		{Address: 0xF000, Data: []byte{1, 2, 3, 4}},
+		{Address: 0xF010, Data: []byte{0xFF, 0xFE}},
+	}})
+}
+
+func TestReadTITXTErrors(t *testing.T) {
+
+	for _, c := range []struct {
+		name   string
+		src    string
+		kind   error
+		line   int
+		column int
+	}{
+		{"NoAddress", "01 02\nq\n", ErrStructure, 1, 1},
+		{"BadAddress", "@12G4\nq\n", ErrBadHexDigit, 1, 2},
+		{"LongAddress", "@123456789\nq\n", ErrBadHexDigit, 1, 2},
+		{"BadByte", "@0000\n01 0G\nq\n", ErrBadHexDigit, 2, 4},
+		{"OddDigits", "@0000\n01 234\nq\n", ErrBadHexDigit, 2, 4},
+		{"AddressSpace", "@FFFFFFFF\n01 02\nq\n", ErrStructure, 2, 1},
+		{"NoEnd", "@0000\n01\n", ErrStructure, 3, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ReadTITXT(bytes.NewBufferString(c.src))
+
+			var e *InvalidRecordError
+			if !errors.Is(err, c.kind) || !errors.As(err, &e) || e.Line != c.line || e.Column != c.column {
+				t.Fatalf("expected %v at line %d column %d, got %v", c.kind, c.line, c.column, err)
+			}
+		})
+	}
+}
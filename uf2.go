@@ -0,0 +1,217 @@
+package ihex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// uf2BlockSize is the size of every UF2 block
+	uf2BlockSize = 512
+
+	// uf2MaxPayloadSize is the largest number of data bytes a UF2 block can hold
+	uf2MaxPayloadSize = 476
+
+	// uf2DefaultPayloadSize is the number of data bytes written in each UF2 block when UF2Options does not specify one
+	uf2DefaultPayloadSize = 256
+
+	// uf2DataOffset is the offset of the data in a UF2 block
+	uf2DataOffset = 32
+
+	// The magic numbers that identify a UF2 block
+	uf2MagicStart0 uint32 = 0x0A324655
+	uf2MagicStart1 uint32 = 0x9E5D5157
+	uf2MagicEnd    uint32 = 0x0AB16F30
+
+	// UF2FlagNotMainFlash marks a UF2 block whose data is not meant for the main flash, so it is skipped when reading
+	UF2FlagNotMainFlash uint32 = 0x00000001
+
+	// UF2FlagFileContainer marks a UF2 block that holds part of a file instead of data at a target address
+	UF2FlagFileContainer uint32 = 0x00001000
+
+	// UF2FlagFamilyIDPresent marks a UF2 block whose file size field holds the family ID of the board it is meant for
+	UF2FlagFamilyIDPresent uint32 = 0x00002000
+)
+
+// UF2Options defines optional settings for reading and writing UF2 files
+type UF2Options struct {
+	// FamilyID identifies the board family the data is meant for, such as 0xE48BFF56 for the RP2040.
+	// When writing, a non-zero FamilyID is written to every block. When reading, a non-zero FamilyID skips blocks of other families.
+	FamilyID uint32
+
+	// PayloadSize is the number of data bytes written in each block, up to 476. A value of 0 writes 256 bytes per block.
+	// Blocks start at addresses that are multiples of PayloadSize.
+	PayloadSize int
+
+	// Fill is the value of the bytes of a block that have no data in the image
+	Fill byte
+}
+
+// ReadUF2 reads a UF2 file and creates an image of its contents.
+// Every block must start and end with the UF2 magic numbers, and the blocks of each family must be numbered in order from 0 to one less than their block count.
+// Blocks flagged as not meant for the main flash and file container blocks are skipped, as are blocks for a family other than opts.FamilyID if it is set.
+// Returns the new image or an IndexedRecordError holding the index of the first malformed block.
+func ReadUF2(r io.Reader, opts UF2Options) (*Image, error) {
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	block := make([]byte, uf2BlockSize)
+	next, count := uint32(0), uint32(0)
+	index := 0
+
+	for ; ; index++ {
+		if _, err := io.ReadFull(r, block); err == io.EOF {
+			break
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, uf2Error(index, ErrLengthMismatch, "UF2 block is shorter than %d bytes", uf2BlockSize)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if binary.LittleEndian.Uint32(block[0:]) != uf2MagicStart0 || binary.LittleEndian.Uint32(block[4:]) != uf2MagicStart1 || binary.LittleEndian.Uint32(block[uf2BlockSize-4:]) != uf2MagicEnd {
+			return nil, uf2Error(index, ErrBadStartCode, "UF2 block does not contain the UF2 magic numbers")
+		}
+
+		flags := binary.LittleEndian.Uint32(block[8:])
+		address := binary.LittleEndian.Uint32(block[12:])
+		size := binary.LittleEndian.Uint32(block[16:])
+		blockNo := binary.LittleEndian.Uint32(block[20:])
+		numBlocks := binary.LittleEndian.Uint32(block[24:])
+		familyID := binary.LittleEndian.Uint32(block[28:])
+
+		// block numbering starts over at 0 for each family of a file holding several
+		if blockNo == 0 && next == count {
+			next, count = 0, numBlocks
+		}
+
+		if blockNo != next || numBlocks != count {
+			return nil, uf2Error(index, ErrStructure, "UF2 block number %d of %d does not follow block %d of %d", blockNo, numBlocks, next, count)
+		}
+
+		if blockNo >= numBlocks {
+			return nil, uf2Error(index, ErrStructure, "UF2 block number %d is not less than the block count %d", blockNo, numBlocks)
+		}
+		next++
+
+		if size > uf2MaxPayloadSize {
+			return nil, uf2Error(index, ErrLengthMismatch, "UF2 block payload size %d exceeds the maximum of %d bytes", size, uf2MaxPayloadSize)
+		}
+
+		if flags&(UF2FlagNotMainFlash|UF2FlagFileContainer) != 0 {
+			continue
+		}
+
+		if opts.FamilyID != 0 && flags&UF2FlagFamilyIDPresent != 0 && familyID != opts.FamilyID {
+			continue
+		}
+
+		if uint64(address)+uint64(size) > 1<<32 {
+			return nil, uf2Error(index, ErrStructure, "UF2 block data at address %08X extends past the end of the 32 bit address space", address)
+		}
+
+		img.Set(address, block[uf2DataOffset:uf2DataOffset+size])
+	}
+
+	if next != count {
+		return nil, uf2Error(index, ErrStructure, "UF2 file ends after block %d of %d", next, count)
+	}
+
+	return img, nil
+}
+
+// WriteUF2 writes the data of an image to the provided writer as a UF2 file.
+// Data is written in blocks of opts.PayloadSize bytes at addresses that are multiples of opts.PayloadSize. Bytes of a block that have no data in the image are set to opts.Fill.
+// The start address of the image is not written, since UF2 files have no way to store it.
+// Returns an error if the payload size is invalid or any errors generated by the provided writer.
+func WriteUF2(w io.Writer, img *Image, opts UF2Options) error {
+
+	payloadSize := opts.PayloadSize
+	if payloadSize == 0 {
+		payloadSize = uf2DefaultPayloadSize
+	}
+
+	if payloadSize < 0 || payloadSize > uf2MaxPayloadSize {
+		return fmt.Errorf("UF2 payload size cannot exceed %d bytes and must be greater than 0 bytes. Requested payload size: %d bytes", uf2MaxPayloadSize, payloadSize)
+	}
+
+	blocks := img.blocks(uint64(payloadSize), opts.Fill)
+
+	numBlocks := 0
+	for _, seg := range blocks.Segments {
+		numBlocks += (len(seg.Data) + payloadSize - 1) / payloadSize
+	}
+
+	flags := uint32(0)
+	if opts.FamilyID != 0 {
+		flags |= UF2FlagFamilyIDPresent
+	}
+
+	bw := bufio.NewWriter(w)
+	block := make([]byte, uf2BlockSize)
+	blockNo := 0
+
+	for _, seg := range blocks.Segments {
+		for i := 0; i < len(seg.Data); i += payloadSize {
+			end := i + payloadSize
+			if end > len(seg.Data) {
+				end = len(seg.Data)
+			}
+
+			for j := range block {
+				block[j] = 0
+			}
+
+			binary.LittleEndian.PutUint32(block[0:], uf2MagicStart0)
+			binary.LittleEndian.PutUint32(block[4:], uf2MagicStart1)
+			binary.LittleEndian.PutUint32(block[8:], flags)
+			binary.LittleEndian.PutUint32(block[12:], seg.Address+uint32(i))
+			binary.LittleEndian.PutUint32(block[16:], uint32(end-i))
+			binary.LittleEndian.PutUint32(block[20:], uint32(blockNo))
+			binary.LittleEndian.PutUint32(block[24:], uint32(numBlocks))
+			binary.LittleEndian.PutUint32(block[28:], opts.FamilyID)
+			copy(block[uf2DataOffset:], seg.Data[i:end])
+			binary.LittleEndian.PutUint32(block[uf2BlockSize-4:], uf2MagicEnd)
+
+			if _, err := bw.Write(block); err != nil {
+				return err
+			}
+			blockNo++
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadUF2File reads a UF2 file and converts it to an I32HEX file with records of 16 bytes.
+// Returns the new I32HEX file or an IndexedRecordError holding the index of the first malformed block.
+func ReadUF2File(r io.Reader, opts UF2Options) (*I32HEXFile, error) {
+	return newImageFile[i32hexType](ReadUF2(r, opts))
+}
+
+// WriteUF2File converts an IHEX file to a UF2 file and writes it to the provided writer.
+// Returns an error if the payload size is invalid or any errors generated by the provided writer.
+func WriteUF2File(w io.Writer, f File, opts UF2Options) error {
+
+	img, err := NewImage(f)
+	if err != nil {
+		return err
+	}
+	return WriteUF2(w, img, opts)
+}
+
+// uf2Error creates an IndexedRecordError of the specified kind for the UF2 block at index
+func uf2Error(index int, kind error, format string, args ...interface{}) error {
+
+	return &IndexedRecordError{
+		Index: index,
+		RecordError: &InvalidRecordError{
+			Message: fmt.Sprintf(format, args...),
+			Kind:    kind,
+		},
+	}
+}
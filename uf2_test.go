@@ -0,0 +1,125 @@
+package ihex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// uf2Block returns a UF2 block holding data at address
+func uf2Block(flags uint32, address uint32, data []byte, blockNo uint32, numBlocks uint32, familyID uint32) []byte {
+
+	block := make([]byte, uf2BlockSize)
+	binary.LittleEndian.PutUint32(block[0:], uf2MagicStart0)
+	binary.LittleEndian.PutUint32(block[4:], uf2MagicStart1)
+	binary.LittleEndian.PutUint32(block[8:], flags)
+	binary.LittleEndian.PutUint32(block[12:], address)
+	binary.LittleEndian.PutUint32(block[16:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(block[20:], blockNo)
+	binary.LittleEndian.PutUint32(block[24:], numBlocks)
+	binary.LittleEndian.PutUint32(block[28:], familyID)
+	copy(block[uf2DataOffset:], data)
+	binary.LittleEndian.PutUint32(block[uf2BlockSize-4:], uf2MagicEnd)
+	return block
+}
+
+func TestWriteUF2Blocks(t *testing.T) {
+
+	img := &Image{Segments: []Segment{{Address: 0x10000010, Data: testData(300)}}}
+
+	var buf bytes.Buffer
+	if err := WriteUF2(&buf, img, UF2Options{FamilyID: 0xE48BFF56, Fill: 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 300 bytes from 0x10000010 cover the blocks at 0x10000000 and 0x10000100
+	if buf.Len() != 2*uf2BlockSize {
+		t.Fatalf("wrote %d bytes, expected 2 blocks", buf.Len())
+	}
+
+	for i, want := range [][]byte{
+		uf2Block(UF2FlagFamilyIDPresent, 0x10000000, append(bytes.Repeat([]byte{0xFF}, 16), testData(240)...), 0, 2, 0xE48BFF56),
+		uf2Block(UF2FlagFamilyIDPresent, 0x10000100, append(testData(300)[240:], bytes.Repeat([]byte{0xFF}, 196)...), 1, 2, 0xE48BFF56),
+	} {
+		if got := buf.Bytes()[i*uf2BlockSize : (i+1)*uf2BlockSize]; !bytes.Equal(got, want) {
+			t.Fatalf("block %d does not match the expected block:\n% X\nexpected:\n% X", i, got[:32], want[:32])
+		}
+	}
+
+	read, err := ReadUF2(&buf, UF2Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read.Segments) != 1 || read.Segments[0].Address != 0x10000000 || !bytes.Equal(read.Segments[0].Data[16:316], testData(300)) {
+		t.Fatalf("read back %d segments", len(read.Segments))
+	}
+}
+
+func TestReadUF2Filtering(t *testing.T) {
+
+	src := bytes.Join([][]byte{
+		uf2Block(0, 0x000, []byte{1}, 0, 4, 0),
+		uf2Block(UF2FlagNotMainFlash, 0x100, []byte{2}, 1, 4, 0),
+		uf2Block(UF2FlagFileContainer, 0x200, []byte{3}, 2, 4, 0),
+		uf2Block(UF2FlagFamilyIDPresent, 0x300, []byte{4}, 3, 4, 0x1234),
+
+		// a second family starts its block numbering over
+		uf2Block(UF2FlagFamilyIDPresent, 0x400, []byte{5}, 0, 1, 0x5678),
+	}, nil)
+
+	for _, c := range []struct {
+		familyID  uint32
+		addresses []uint32
+	}{
+		{0, []uint32{0x000, 0x300, 0x400}},
+		{0x1234, []uint32{0x000, 0x300}},
+		{0x5678, []uint32{0x000, 0x400}},
+	} {
+		img, err := ReadUF2(bytes.NewReader(src), UF2Options{FamilyID: c.familyID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(img.Segments) != len(c.addresses) {
+			t.Fatalf("family %08X: read %d segments, expected %d", c.familyID, len(img.Segments), len(c.addresses))
+		}
+
+		for i, a := range c.addresses {
+			if img.Segments[i].Address != a {
+				t.Fatalf("family %08X: segment %d at %08X, expected %08X", c.familyID, i, img.Segments[i].Address, a)
+			}
+		}
+	}
+}
+
+func TestReadUF2Errors(t *testing.T) {
+
+	badMagic := uf2Block(0, 0, []byte{1}, 0, 1, 0)
+	badMagic[uf2BlockSize-1] ^= 0xFF
+
+	for _, c := range []struct {
+		name  string
+		src   []byte
+		kind  error
+		index int
+	}{
+		{"MagicEnd", badMagic, ErrBadStartCode, 0},
+		{"MagicStart", append(uf2Block(0, 0, []byte{1}, 0, 2, 0), make([]byte, uf2BlockSize)...), ErrBadStartCode, 1},
+		{"Short", uf2Block(0, 0, []byte{1}, 0, 1, 0)[:100], ErrLengthMismatch, 0},
+		{"SkippedBlock", append(uf2Block(0, 0, []byte{1}, 0, 3, 0), uf2Block(0, 0x100, []byte{1}, 2, 3, 0)...), ErrStructure, 1},
+		{"BlockCount", append(uf2Block(0, 0, []byte{1}, 0, 3, 0), uf2Block(0, 0x100, []byte{1}, 1, 4, 0)...), ErrStructure, 1},
+		{"MissingBlocks", uf2Block(0, 0, []byte{1}, 0, 2, 0), ErrStructure, 1},
+		{"PayloadSize", uf2Block(0, 0, make([]byte, uf2MaxPayloadSize+1), 0, 1, 0), ErrLengthMismatch, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ReadUF2(bytes.NewReader(c.src), UF2Options{})
+
+			var e *IndexedRecordError
+			if !errors.Is(err, c.kind) || !errors.As(err, &e) || e.Index != c.index {
+				t.Fatalf("expected %v at block %d, got %v", c.kind, c.index, err)
+			}
+		})
+	}
+}
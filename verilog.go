@@ -0,0 +1,198 @@
+package ihex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// VerilogOptions defines the layout of the memory words in a Verilog $readmemh or $readmemb memory file
+type VerilogOptions struct {
+	// WordWidth is the width of each memory word in bits: 8, 16, 32 or 64. A value of 0 uses 8 bit words.
+	WordWidth int
+
+	// BigEndian stores the lowest addressed byte of each word in its most significant bits instead of its least significant bits
+	BigEndian bool
+
+	// Binary uses the binary digits of $readmemb instead of the hexadecimal digits of $readmemh
+	Binary bool
+
+	// Depth is the number of words in the memory. Data at or past word address Depth is an error. A value of 0 means no limit.
+	Depth int
+
+	// Fill is the value of the bytes of a partially filled word that have no data in the image
+	Fill byte
+}
+
+// WriteVerilog writes the data of an image to the provided writer as a Verilog memory file, for use with $readmemh (or $readmemb if opts.Binary is set).
+// Each segment of the image starts with an "@ADDR" directive holding its word address, followed by one word per line.
+// Bytes of partially filled words that have no data in the image are set to opts.Fill.
+// Returns an error if the options are invalid, the image does not fit in opts.Depth words, or any errors generated by the provided writer.
+func WriteVerilog(w io.Writer, img *Image, opts VerilogOptions) error {
+
+	wb, err := memoryWordBytes(opts.WordWidth)
+	if err != nil {
+		return err
+	}
+
+	words := img.blocks(uint64(wb), opts.Fill)
+
+	if n := len(words.Segments); n > 0 && opts.Depth > 0 && words.Segments[n-1].End()/uint64(wb) > uint64(opts.Depth) {
+		return fmt.Errorf("Image data at address %08X does not fit in a memory of %d words", words.Segments[n-1].End()-1, opts.Depth)
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, 65)
+
+	for _, seg := range words.Segments {
+		if _, err := fmt.Fprintf(bw, "@%X\n", seg.Address/uint32(wb)); err != nil {
+			return err
+		}
+
+		for i := 0; i < len(seg.Data); i += wb {
+			word := packWord(seg.Data[i:i+wb], opts.BigEndian)
+
+			buf = buf[:0]
+			if opts.Binary {
+				for j := wb*8 - 1; j >= 0; j-- {
+					buf = append(buf, '0'+byte(word>>j&1))
+				}
+			} else {
+				for j := wb - 1; j >= 0; j-- {
+					buf = appendHexByte(buf, recordHexDigits, byte(word>>(8*j)))
+				}
+			}
+			buf = append(buf, '\n')
+
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadVerilog reads a Verilog memory file, as used by $readmemh (or $readmemb if opts.Binary is set), and creates an image of its contents.
+// "@ADDR" directives set the word address of the words that follow them. Words are separated by whitespace and may contain '_' separators.
+// Line (//) and block (/* */) comments are skipped. Words containing x or z digits are rejected, since they have no byte value.
+// Returns the new image or an InvalidRecordError pointing at the first malformed word or directive.
+func ReadVerilog(r io.Reader, opts VerilogOptions) (*Image, error) {
+
+	wb, err := memoryWordBytes(opts.WordWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{
+		Segments: make([]Segment, 0),
+	}
+
+	base := 16
+	digitsPerWord := wb * 2
+	if opts.Binary {
+		base = 2
+		digitsPerWord = wb * 8
+	}
+
+	scanner := bufio.NewScanner(r)
+	word := make([]byte, wb)
+	address := uint64(0)
+	inComment := false
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+
+		for i := 0; i < len(text); {
+			if inComment {
+				end := bytes.Index(text[i:], []byte("*/"))
+				if end < 0 {
+					break
+				}
+				inComment = false
+				i += end + 2
+				continue
+			}
+
+			if c := text[i]; c == ' ' || c == '\t' || c == '\r' {
+				i++
+				continue
+			}
+
+			if bytes.HasPrefix(text[i:], []byte("//")) {
+				break
+			}
+
+			if bytes.HasPrefix(text[i:], []byte("/*")) {
+				inComment = true
+				i += 2
+				continue
+			}
+
+			start := i
+			for i < len(text) && text[i] != ' ' && text[i] != '\t' && text[i] != '\r' && !bytes.HasPrefix(text[i:], []byte("//")) && !bytes.HasPrefix(text[i:], []byte("/*")) {
+				i++
+			}
+			token := bytes.ReplaceAll(text[start:i], []byte("_"), nil)
+
+			if len(token) > 0 && token[0] == '@' {
+				a, err := strconv.ParseUint(string(token[1:]), 16, 64)
+				if err != nil {
+					return nil, newLineError(ErrBadHexDigit, line, start+1, text, "Invalid address directive '%s'", text[start:i])
+				}
+				address = a
+				continue
+			}
+
+			if len(token) == 0 || len(token) > digitsPerWord {
+				return nil, newLineError(ErrLengthMismatch, line, start+1, text, "Word '%s' does not fit in %d bits", text[start:i], wb*8)
+			}
+
+			value, err := strconv.ParseUint(string(token), base, 64)
+			if err != nil {
+				return nil, newLineError(ErrBadHexDigit, line, start+1, text, "Invalid word '%s'", text[start:i])
+			}
+
+			if opts.Depth > 0 && address >= uint64(opts.Depth) {
+				return nil, newLineError(ErrStructure, line, start+1, text, "Word address %X is past the memory depth of %d words", address, opts.Depth)
+			}
+
+			// the address is checked before multiplying by the word size, since a large address directive would overflow the product
+			if address >= (1<<32)/uint64(wb) {
+				return nil, newLineError(ErrStructure, line, start+1, text, "Word address %X is past the end of the 32 bit address space", address)
+			}
+
+			unpackWord(word, value, opts.BigEndian)
+
+			img.Set(uint32(address*uint64(wb)), word)
+			address++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// ReadVerilogFile reads a Verilog memory file, as used by $readmemh (or $readmemb if opts.Binary is set), and converts it to an I32HEX file with records of 16 bytes.
+// Returns the new I32HEX file or an InvalidRecordError pointing at the first malformed word or directive.
+func ReadVerilogFile(r io.Reader, opts VerilogOptions) (*I32HEXFile, error) {
+	return newImageFile[i32hexType](ReadVerilog(r, opts))
+}
+
+// WriteVerilogFile converts an IHEX file to a Verilog memory file and writes it to the provided writer.
+// Returns an error if the options are invalid, the file's data does not fit in opts.Depth words, or any errors generated by the provided writer.
+func WriteVerilogFile(w io.Writer, f File, opts VerilogOptions) error {
+
+	img, err := NewImage(f)
+	if err != nil {
+		return err
+	}
+	return WriteVerilog(w, img, opts)
+}
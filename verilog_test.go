@@ -0,0 +1,31 @@
+package ihex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadVerilogAddressSpace(t *testing.T) {
+
+	for _, c := range []struct {
+		src   string
+		width int
+		ok    bool
+	}{
+		{"@FFFFFFFF 5A", 8, true},
+		{"@3FFFFFFF 01234567", 32, true},
+		{"@100000000 5A", 8, false},
+		{"@4000000000000000 01234567", 32, false},
+		{"@FFFFFFFFFFFFFFFF 5A", 8, false},
+		{"@2000000000000000 0123456789ABCDEF", 64, false},
+	} {
+		img, err := ReadVerilog(strings.NewReader(c.src), VerilogOptions{WordWidth: c.width})
+
+		if c.ok && err != nil {
+			t.Errorf("%q with %d bit words: %v", c.src, c.width, err)
+		} else if !c.ok && !errors.Is(err, ErrStructure) {
+			t.Errorf("%q with %d bit words was read as %v, %v", c.src, c.width, img, err)
+		}
+	}
+}